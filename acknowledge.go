@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// acknowledgePackets marks the given packets as reviewed, dropping them
+// from the /api/packets/malicious queue. It returns how many were newly
+// acknowledged.
+func acknowledgePackets(ctx context.Context, ids []int64) (int64, error) {
+	res, err := db().ExecContext(ctx, tagQuery(`
+		UPDATE packet_info SET acknowledged = true
+		WHERE id = ANY($1) AND NOT acknowledged
+	`), pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// handleAcknowledgeAPI acknowledges a batch of packets posted as
+// {"ids": [1, 2, 3]}. Admin only.
+func handleAcknowledgeAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		IDs []int64 `json:"ids"`
+	}
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if len(req.IDs) == 0 {
+		http.Error(w, "No ids submitted", http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) > maxPacketLimit {
+		http.Error(w, fmt.Sprintf("At most %d ids per request", maxPacketLimit), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	n, err := acknowledgePackets(ctx, req.IDs)
+	if err != nil {
+		http.Error(w, "Error updating data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+	log.Printf("Acknowledged %d packet(s) at the request of %s", n, clientIP(r))
+
+	writeJSON(w, r, map[string]int64{"acknowledged": n})
+}
+
+// handleMaliciousAPI lists packets flagged malicious, newest first, as an
+// alert queue: acknowledged ones are left out unless
+// ?include_acknowledged=true. It takes the /api/packets filters.
+func handleMaliciousAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	filter, err := parsePacketFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.MaliciousOnly = true
+	if s := r.URL.Query().Get("include_acknowledged"); s != "" {
+		include, err := strconv.ParseBool(s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid include_acknowledged %q", s), http.StatusBadRequest)
+			return
+		}
+		filter.ExcludeAcknowledged = !include
+	} else {
+		filter.ExcludeAcknowledged = true
+	}
+
+	packets, err := getPackets(ctx, filter)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+	if packets == nil {
+		packets = []PacketInfo{}
+	}
+
+	writeJSON(w, r, packets)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// alertWebhookURL receives alerts as a JSON POST (ALERT_WEBHOOK_URL). When
+// unset, alerts are only logged.
+var alertWebhookURL string
+
+// Failed webhook deliveries are retried up to alertRetryMax attempts in
+// total, doubling the wait from alertRetryBackoff (ALERT_RETRY_MAX,
+// ALERT_RETRY_BACKOFF).
+var (
+	alertRetryMax     = 5
+	alertRetryBackoff = time.Second
+)
+
+const maxAlertBackoff = time.Minute
+
+var alertClient = &http.Client{Timeout: 10 * time.Second}
+
+// Alert is the JSON body posted to the webhook.
+type Alert struct {
+	Type           string         `json:"type"`
+	Message        string         `json:"message"`
+	Time           time.Time      `json:"time"`
+	IdempotencyKey string         `json:"idempotency_key"`
+	Malicious      int            `json:"malicious"`
+	Critical       bool           `json:"critical"`
+	Details        map[string]any `json:"details,omitempty"`
+}
+
+// alertKey derives an idempotency key from the alert type and the packets
+// that triggered it, so re-raising the same alert yields the same key.
+func alertKey(typ string, packetIDs []int) string {
+	ids := slices.Clone(packetIDs)
+	slices.Sort(ids)
+	h := sha256.New()
+	h.Write([]byte(typ))
+	for _, id := range ids {
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.Itoa(id)))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+const createAlertDeliveriesTable = `
+	CREATE TABLE IF NOT EXISTS alert_deliveries (
+		key          text        PRIMARY KEY,
+		type         text        NOT NULL,
+		delivered_at timestamptz NOT NULL DEFAULT now()
+	)
+`
+
+// alertKeysPersisted is set once alert_deliveries exists. Without it every
+// alert is delivered, as before.
+var alertKeysPersisted bool
+
+func initAlertDeliveries(ctx context.Context) {
+	if _, err := db().ExecContext(ctx, tagQuery(createAlertDeliveriesTable)); err != nil {
+		log.Printf("Alert deduplication disabled, could not create alert_deliveries: %v", err)
+		return
+	}
+	alertKeysPersisted = true
+}
+
+func alertDelivered(ctx context.Context, key string) (bool, error) {
+	var delivered bool
+	err := db().QueryRowContext(ctx, tagQuery(`SELECT EXISTS (SELECT 1 FROM alert_deliveries WHERE key = $1)`), key).Scan(&delivered)
+	return delivered, err
+}
+
+func markAlertDelivered(ctx context.Context, a Alert) error {
+	_, err := db().ExecContext(ctx, tagQuery(`INSERT INTO alert_deliveries (key, type) VALUES ($1, $2) ON CONFLICT (key) DO NOTHING`),
+		a.IdempotencyKey, a.Type)
+	return err
+}
+
+// sendAlert logs a and posts it to the webhook, if configured, or holds it
+// for the summary if it isn't critical and quiet hours are on. An alert
+// whose key was already delivered is skipped. A crash between the POST and
+// recording the key can still send it twice, which is what the key in the
+// payload (and Idempotency-Key header) lets the receiver detect.
+func sendAlert(ctx context.Context, a Alert) error {
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+	if a.IdempotencyKey == "" {
+		// Callers with triggering packets set the key; otherwise the
+		// alert is identified by its text.
+		a.IdempotencyKey = alertKey(a.Type+"\x00"+a.Message, nil)
+	}
+	a.Critical = isCriticalAlert(a)
+	if !a.Critical && inQuietHours(a.Time) {
+		log.Printf("Alert [%s] held for quiet hours: %s", a.Type, a.Message)
+		holdAlert(a)
+		return nil
+	}
+	return deliverAlert(ctx, a)
+}
+
+// deliverAlert logs a and posts it to the webhook, bypassing quiet hours.
+func deliverAlert(ctx context.Context, a Alert) error {
+	log.Printf("Alert [%s]: %s", a.Type, a.Message)
+	if alertWebhookURL == "" {
+		return nil
+	}
+
+	if alertKeysPersisted {
+		delivered, err := alertDelivered(ctx, a.IdempotencyKey)
+		if err != nil {
+			log.Printf("Alert deduplication check failed, sending anyway: %v", err)
+		} else if delivered {
+			log.Printf("Alert %s already delivered, skipping", a.IdempotencyKey)
+			return nil
+		}
+	}
+
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	backoff := alertRetryBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := postAlert(ctx, a.IdempotencyKey, body)
+		if err == nil {
+			break
+		}
+		if !retry || attempt >= alertRetryMax {
+			return fmt.Errorf("alert delivery failed after %d attempt(s): %w", attempt, err)
+		}
+		log.Printf("Alert delivery attempt %d failed, retrying in %s: %v", attempt, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxAlertBackoff)
+	}
+
+	if alertKeysPersisted {
+		if err := markAlertDelivered(ctx, a); err != nil {
+			log.Printf("Could not record delivered alert %s: %v", a.IdempotencyKey, err)
+		}
+	}
+	return nil
+}
+
+// postAlert makes one delivery attempt. Network errors, 429 and 5xx
+// responses are worth retrying; other failures are not.
+func postAlert(ctx context.Context, key string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, alertWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+
+	resp, err := alertClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("alert webhook returned %s", resp.Status)
+	}
+	return false, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// apiRoute is one API endpoint, its path relative to the version prefix.
+type apiRoute struct {
+	path    string
+	handler http.HandlerFunc
+}
+
+// apiVersions are the versioned prefixes the API is served under. A new
+// version is added here and registered with its own route list, reusing
+// the handlers it doesn't change.
+var apiVersions = []string{"/api/v1"}
+
+func registerAPI(mux *http.ServeMux, prefix string, routes []apiRoute) {
+	for _, route := range routes {
+		handler := route.handler
+		if known, ok := routeParams[route.path]; ok {
+			handler = checkParams(known, handler)
+		}
+		mux.HandleFunc(prefix+route.path, handler)
+	}
+}
+
+// unversionedPath maps /api/v1/stats to /api/stats, so settings keyed by
+// route (cache max-ages, stale fallback) cover every version of it.
+func unversionedPath(path string) string {
+	for _, prefix := range apiVersions {
+		if rest, ok := strings.CutPrefix(path, prefix+"/"); ok {
+			return "/api/" + rest
+		}
+	}
+	return path
+}
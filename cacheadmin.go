@@ -0,0 +1,126 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// cacheCounters counts lookups of one in-memory cache for /admin/cache.
+type cacheCounters struct {
+	hits, misses atomic.Int64
+}
+
+func (c *cacheCounters) record(hit bool) {
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+var (
+	staleCounters       cacheCounters
+	stmtCounters        cacheCounters
+	countsCounters      cacheCounters
+	arrivalRateCounters cacheCounters
+)
+
+type cacheInfo struct {
+	Name     string `json:"name"`
+	Entries  int    `json:"entries"`
+	Capacity int    `json:"capacity"`
+	TTL      string `json:"ttl,omitempty"` // empty when entries don't expire
+	Hits     int64  `json:"hits"`
+	Misses   int64  `json:"misses"`
+}
+
+// adminCache is one in-memory cache that /admin/cache reports on and
+// /admin/cache/flush can empty.
+type adminCache struct {
+	name     string
+	counters *cacheCounters
+	info     func() cacheInfo
+	flush    func()
+}
+
+var adminCaches = []adminCache{
+	{"stale", &staleCounters, func() cacheInfo {
+		staleMu.Lock()
+		defer staleMu.Unlock()
+		return cacheInfo{Entries: len(staleCache), Capacity: maxStaleEntries, TTL: staleTTL.String()}
+	}, clearStale},
+	{"statements", &stmtCounters, func() cacheInfo {
+		stmtCache.Lock()
+		defer stmtCache.Unlock()
+		return cacheInfo{Entries: len(stmtCache.stmts), Capacity: stmtCacheSize}
+	}, flushStmtCache},
+	{"stream_counts", &countsCounters, func() cacheInfo {
+		sharedCounts.mu.Lock()
+		defer sharedCounts.mu.Unlock()
+		return cacheInfo{Entries: cachedEntry(sharedCounts.counts.At, countStreamInterval), Capacity: 1, TTL: countStreamInterval.String()}
+	}, func() {
+		sharedCounts.mu.Lock()
+		defer sharedCounts.mu.Unlock()
+		sharedCounts.counts = packetCounts{}
+	}},
+	{"arrival_rate", &arrivalRateCounters, func() cacheInfo {
+		arrivalRate.mu.Lock()
+		defer arrivalRate.mu.Unlock()
+		return cacheInfo{Entries: cachedEntry(arrivalRate.at, pollHintRefresh), Capacity: 1, TTL: pollHintRefresh.String()}
+	}, func() {
+		arrivalRate.mu.Lock()
+		defer arrivalRate.mu.Unlock()
+		arrivalRate.at = time.Time{}
+	}},
+}
+
+// cachedEntry is 1 while a single-value cache stored at at is still fresh.
+func cachedEntry(at time.Time, ttl time.Duration) int {
+	if time.Since(at) < ttl {
+		return 1
+	}
+	return 0
+}
+
+// handleAdminCache reports size, capacity, TTL and hit/miss counts of every
+// in-memory cache.
+func handleAdminCache(w http.ResponseWriter, r *http.Request) {
+	caches := make([]cacheInfo, len(adminCaches))
+	for i, c := range adminCaches {
+		info := c.info()
+		info.Name = c.name
+		info.Hits, info.Misses = c.counters.hits.Load(), c.counters.misses.Load()
+		caches[i] = info
+	}
+	writeJSON(w, r, map[string]any{"caches": caches})
+}
+
+// handleAdminCacheFlush empties every cache, or only ?name=. Hit/miss
+// counters keep counting across flushes.
+func handleAdminCacheFlush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name != "" && !slices.ContainsFunc(adminCaches, func(c adminCache) bool { return c.name == name }) {
+		http.Error(w, "Unknown cache "+name, http.StatusNotFound)
+		return
+	}
+
+	flushed := []string{}
+	for _, c := range adminCaches {
+		if name == "" || c.name == name {
+			c.flush()
+			flushed = append(flushed, c.name)
+		}
+	}
+	log.Printf("Flushed caches %v at the request of %s", flushed, clientIP(r))
+
+	writeJSON(w, r, map[string]any{"flushed": flushed})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// cacheMaxAges holds the Cache-Control max-age for slowly changing
+// endpoints, keyed by path. CACHE_MAX_AGE sets the default for all of them
+// and CACHE_MAX_AGE_ROUTES overrides single paths; a zero age disables
+// caching for that path.
+var cacheMaxAges = map[string]time.Duration{}
+
+var cacheableRoutes = []string{
+	"/api/schema",
+	"/api/compare",
+	"/api/sparkline",
+	"/api/trends",
+	"/api/trends/malicious-ratio",
+	"/api/stats",
+	"/api/stats/checksums",
+	"/api/stats/ttl",
+	"/api/stats/flags",
+	"/api/stats/subnets",
+	"/api/stats/size-threat",
+	"/api/top-targets",
+	"/api/protocols",
+}
+
+const defaultCacheMaxAge = 5 * time.Second
+
+// schemaCacheMaxAge is the default for /api/schema, which only changes
+// between deployments; clients revalidate it by ETag once it expires.
+const schemaCacheMaxAge = 24 * time.Hour
+
+// configureCacheMaxAges applies def (defaultCacheMaxAge when empty) to every
+// cacheable route, then parses overrides of the form
+// "/api/stats=30s,/api/schema=1h".
+func configureCacheMaxAges(defStr, overrides string) {
+	def := defaultCacheMaxAge
+	if defStr != "" {
+		d, err := time.ParseDuration(defStr)
+		if err != nil || d < 0 {
+			log.Printf("Invalid CACHE_MAX_AGE %q, using %s", defStr, def)
+		} else {
+			def = d
+		}
+	}
+	for _, route := range cacheableRoutes {
+		cacheMaxAges[route] = def
+	}
+	cacheMaxAges["/api/schema"] = schemaCacheMaxAge
+	for _, part := range strings.Split(overrides, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		route, s, ok := strings.Cut(part, "=")
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if !ok || err != nil || d < 0 {
+			log.Printf("Ignoring invalid cache max-age %q", part)
+			continue
+		}
+		if _, known := cacheMaxAges[route]; !known {
+			log.Printf("Ignoring cache max-age for non-cacheable route %q", route)
+			continue
+		}
+		cacheMaxAges[route] = d
+	}
+}
+
+// cacheWriter adds Cache-Control only to successful and 304 responses, so
+// errors are never cached by browsers or proxies while a revalidated copy
+// stays fresh for another max-age.
+type cacheWriter struct {
+	http.ResponseWriter
+	header      string
+	wroteHeader bool
+}
+
+func (cw *cacheWriter) WriteHeader(code int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		if code == http.StatusOK || code == http.StatusNotModified {
+			cw.Header().Set("Cache-Control", cw.header)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *cacheWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *cacheWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func cacheControl(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		age := cacheMaxAges[unversionedPath(r.URL.Path)]
+		if age <= 0 || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header := fmt.Sprintf("public, max-age=%d", int(age.Seconds()))
+		next.ServeHTTP(&cacheWriter{ResponseWriter: w, header: header}, r)
+	})
+}
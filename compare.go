@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+type windowStats struct {
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	TotalPackets  int       `json:"total_packets"`
+	Malicious     int       `json:"malicious"`
+	UniqueSources int       `json:"unique_sources"`
+}
+
+type windowDelta struct {
+	TotalPackets  *float64 `json:"total_packets"`
+	Malicious     *float64 `json:"malicious"`
+	UniqueSources *float64 `json:"unique_sources"`
+}
+
+type comparison struct {
+	Window   string      `json:"window"`
+	Current  windowStats `json:"current"`
+	Previous windowStats `json:"previous"`
+	DeltaPct windowDelta `json:"delta_pct"`
+}
+
+func getWindowStats(ctx context.Context, from, to time.Time) (windowStats, error) {
+	query := `
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE malicious > 0),
+		       COUNT(DISTINCT source_ip)
+		FROM packet_info
+		WHERE checked_at >= $1 AND checked_at < $2
+	`
+
+	s := windowStats{From: from, To: to}
+	err := db().QueryRowContext(ctx, tagQuery(query), from, to).Scan(&s.TotalPackets, &s.Malicious, &s.UniqueSources)
+	return s, err
+}
+
+// percentChange returns nil when there is no previous value to compare to.
+func percentChange(previous, current int) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	pct := float64(current-previous) / float64(previous) * 100
+	return &pct
+}
+
+func handleCompareAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	window, err := windowParam(r, "window", time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	current, err := getWindowStats(ctx, now.Add(-window), now)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+	previous, err := getWindowStats(ctx, now.Add(-2*window), now.Add(-window))
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	writeJSON(w, r, comparison{
+		Window:   window.String(),
+		Current:  current,
+		Previous: previous,
+		DeltaPct: windowDelta{
+			TotalPackets:  percentChange(previous.TotalPackets, current.TotalPackets),
+			Malicious:     percentChange(previous.Malicious, current.Malicious),
+			UniqueSources: percentChange(previous.UniqueSources, current.UniqueSources),
+		},
+	})
+}
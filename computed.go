@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// computedFields are derived values attached to every listed packet
+// (COMPUTED_FIELDS, e.g. "risk=3*malicious+suspicious,hops=64-ttl").
+var computedFields []computedField
+
+type computedField struct {
+	Name string
+	expr exprNode
+}
+
+// computedColumns are the packet fields an expression may use.
+var computedColumns = map[string]func(p PacketInfo) float64{
+	"id":              func(p PacketInfo) float64 { return float64(p.ID) },
+	"total_length":    func(p PacketInfo) float64 { return float64(p.TotalLength) },
+	"ttl":             func(p PacketInfo) float64 { return float64(p.TTL) },
+	"header_checksum": func(p PacketInfo) float64 { return float64(p.HeaderChecksum) },
+	"malicious":       func(p PacketInfo) float64 { return float64(p.Malicious) },
+	"suspicious":      func(p PacketInfo) float64 { return float64(p.Suspicious) },
+	"harmless":        func(p PacketInfo) float64 { return float64(p.Harmless) },
+	"undetected":      func(p PacketInfo) float64 { return float64(p.Undetected) },
+}
+
+var computedFieldName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// parseComputedFields parses a comma-separated list of name=expression.
+func parseComputedFields(s string) ([]computedField, error) {
+	var fields []computedField
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, src, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !computedFieldName.MatchString(name) {
+			return nil, fmt.Errorf("invalid COMPUTED_FIELDS entry %q", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("computed field %s defined twice", name)
+		}
+		seen[name] = true
+		expr, err := parseExpr(src)
+		if err != nil {
+			return nil, fmt.Errorf("computed field %s: %w", name, err)
+		}
+		fields = append(fields, computedField{Name: name, expr: expr})
+	}
+	return fields, nil
+}
+
+// computeFields evaluates computedFields for p. Results that are not finite
+// (division by zero) are null.
+func computeFields(p PacketInfo) map[string]*float64 {
+	if len(computedFields) == 0 {
+		return nil
+	}
+	values := make(map[string]*float64, len(computedFields))
+	for _, f := range computedFields {
+		v := f.expr.eval(p)
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			values[f.Name] = nil
+			continue
+		}
+		values[f.Name] = &v
+	}
+	return values
+}
+
+// exprNode is a parsed arithmetic expression over packet columns.
+type exprNode interface {
+	eval(p PacketInfo) float64
+}
+
+type numberNode float64
+
+func (n numberNode) eval(PacketInfo) float64 { return float64(n) }
+
+type columnNode func(p PacketInfo) float64
+
+func (c columnNode) eval(p PacketInfo) float64 { return c(p) }
+
+type negNode struct{ x exprNode }
+
+func (n negNode) eval(p PacketInfo) float64 { return -n.x.eval(p) }
+
+type binaryNode struct {
+	op   byte
+	l, r exprNode
+}
+
+func (b binaryNode) eval(p PacketInfo) float64 {
+	l, r := b.l.eval(p), b.r.eval(p)
+	switch b.op {
+	case '+':
+		return l + r
+	case '-':
+		return l - r
+	case '*':
+		return l * r
+	default:
+		return l / r
+	}
+}
+
+// exprParser is a recursive-descent parser for
+//
+//	expr   = term { ("+" | "-") term }
+//	term   = factor { ("*" | "/") factor }
+//	factor = number | column | "(" expr ")" | "-" factor
+type exprParser struct {
+	src string
+	pos int
+}
+
+func parseExpr(src string) (exprNode, error) {
+	p := &exprParser{src: src}
+	n, err := p.expr(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.src[p.pos], p.pos)
+	}
+	return n, nil
+}
+
+// maxExprDepth bounds nesting so a hostile config can't exhaust the stack.
+const maxExprDepth = 32
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *exprParser) peek() byte {
+	if p.skipSpace(); p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *exprParser) expr(depth int) (exprNode, error) {
+	if depth > maxExprDepth {
+		return nil, fmt.Errorf("expression nested too deeply")
+	}
+	n, err := p.term(depth)
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.pos++
+		r, err := p.term(depth)
+		if err != nil {
+			return nil, err
+		}
+		n = binaryNode{op, n, r}
+	}
+	return n, nil
+}
+
+func (p *exprParser) term(depth int) (exprNode, error) {
+	n, err := p.factor(depth)
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		p.pos++
+		r, err := p.factor(depth)
+		if err != nil {
+			return nil, err
+		}
+		n = binaryNode{op, n, r}
+	}
+	return n, nil
+}
+
+func (p *exprParser) factor(depth int) (exprNode, error) {
+	c := p.peek()
+	switch {
+	case c == '(':
+		p.pos++
+		n, err := p.expr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ) at offset %d", p.pos)
+		}
+		p.pos++
+		return n, nil
+	case c == '-':
+		p.pos++
+		if depth+1 > maxExprDepth {
+			return nil, fmt.Errorf("expression nested too deeply")
+		}
+		x, err := p.factor(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return negNode{x}, nil
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.src[start:p.pos])
+		}
+		return numberNode(v), nil
+	case c >= 'a' && c <= 'z' || c == '_':
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] >= 'a' && p.src[p.pos] <= 'z' || p.src[p.pos] == '_') {
+			p.pos++
+		}
+		name := p.src[start:p.pos]
+		col, ok := computedColumns[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		return columnNode(col), nil
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", c, p.pos)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	p := PacketInfo{ID: 7, TotalLength: 1500, TTL: 60, Malicious: 2, Suspicious: 5}
+	for _, tc := range []struct {
+		src  string
+		want float64
+	}{
+		{"42", 42},
+		{"1.5", 1.5},
+		{"ttl", 60},
+		{"64-ttl", 4},
+		{"1+2*3", 7},
+		{"2*3+1", 7},
+		{"10-4-3", 3},
+		{"100/10/5", 2},
+		{"8-6/2", 5},
+		{"(1+2)*3", 9},
+		{"2*(3+4)*(5-1)", 56},
+		{"((ttl))", 60},
+		{"-ttl+64", 4},
+		{"--3", 3},
+		{"2*-3", -6},
+		{"-(1+2)*2", -6},
+		{" 3 * malicious + suspicious ", 11},
+		{"total_length/(ttl-58)", 750},
+	} {
+		n, err := parseExpr(tc.src)
+		if err != nil {
+			t.Errorf("parseExpr(%q): %v", tc.src, err)
+			continue
+		}
+		if got := n.eval(p); got != tc.want {
+			t.Errorf("parseExpr(%q) = %v, want %v", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src, want string
+	}{
+		{"", "unexpected end"},
+		{"ttl+", "unexpected end"},
+		{"(1+2", "missing )"},
+		{"1+2)", `unexpected ')'`},
+		{"1 2", `unexpected '2'`},
+		{"ttl*%", `unexpected '%'`},
+		{"source_ip", `unknown column "source_ip"`},
+		{"TTL", `unexpected 'T'`},
+		{"1.2.3", `invalid number "1.2.3"`},
+		{strings.Repeat("(", maxExprDepth+2) + "1" + strings.Repeat(")", maxExprDepth+2), "nested too deeply"},
+		{strings.Repeat("-", maxExprDepth+2) + "1", "nested too deeply"},
+	} {
+		_, err := parseExpr(tc.src)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("parseExpr(%q) error = %v, want %q", tc.src, err, tc.want)
+		}
+	}
+}
+
+func TestComputeFieldsDivisionByZero(t *testing.T) {
+	defer func(f []computedField) { computedFields = f }(computedFields)
+	fields, err := parseComputedFields("ratio=malicious/suspicious,nan=malicious/suspicious-malicious/suspicious,hops=64-ttl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	computedFields = fields
+
+	got := computeFields(PacketInfo{TTL: 60, Malicious: 3})
+	if v := got["ratio"]; v != nil {
+		t.Errorf("ratio = %v, want null for division by zero", *v)
+	}
+	if v := got["nan"]; v != nil {
+		t.Errorf("nan = %v, want null", *v)
+	}
+	if v := got["hops"]; v == nil || *v != 4 {
+		t.Errorf("hops = %v, want 4", v)
+	}
+
+	got = computeFields(PacketInfo{Malicious: 3, Suspicious: 2})
+	if v := got["ratio"]; v == nil || math.Abs(*v-1.5) > 1e-9 {
+		t.Errorf("ratio = %v, want 1.5", v)
+	}
+}
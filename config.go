@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// envDuration reads a Go duration (e.g. "5s") from the environment, falling
+// back to def when unset or invalid.
+func envDuration(key string, def time.Duration) time.Duration {
+	s := os.Getenv(key)
+	if s == "" {
+		return def
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using %s", key, s, def)
+		return def
+	}
+	return d
+}
+
+// envInt reads a positive integer from the environment, falling back to def
+// when unset or invalid.
+func envInt(key string, def int) int {
+	s := os.Getenv(key)
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		log.Printf("Invalid %s %q, using %d", key, s, def)
+		return def
+	}
+	return n
+}
+
+// envBool reports whether key is set to a true value ("1", "true", ...).
+func envBool(key string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(key))
+	return v
+}
+
+// loadEnvFiles loads .env.<APP_ENV> (or .env.<ENV>) and then .env. Values
+// already set win, so the process environment overrides the environment-
+// specific file, which in turn overrides .env.
+func loadEnvFiles() {
+	appEnv := os.Getenv("APP_ENV")
+	if appEnv == "" {
+		appEnv = os.Getenv("ENV")
+	}
+
+	files := []string{".env"}
+	if appEnv != "" {
+		files = []string{".env." + appEnv, ".env"}
+	}
+
+	var loaded []string
+	for _, f := range files {
+		if err := godotenv.Load(f); err == nil {
+			loaded = append(loaded, f)
+		}
+	}
+
+	if len(loaded) == 0 {
+		log.Println("No .env file found, using environment variables")
+		return
+	}
+	log.Printf("Loaded environment from %s", strings.Join(loaded, ", "))
+}
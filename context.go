@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// Bounds on ?window= for /api/packets/{id}/context.
+const (
+	defaultContextWindow = 20
+	maxContextWindow     = 500
+)
+
+type packetContext struct {
+	Packet PacketInfo   `json:"packet"`
+	Before []PacketInfo `json:"before"` // oldest first, ending just below packet
+	After  []PacketInfo `json:"after"`  // oldest first, starting just above packet
+}
+
+// getPacketContext loads packet id with up to window neighbours on each
+// side by id. found is false when the packet doesn't exist.
+func getPacketContext(ctx context.Context, id, window int) (pc packetContext, found bool, err error) {
+	self, err := selectPackets(ctx, "WHERE id = $1", []any{id}, "id", 1)
+	if err != nil || len(self) == 0 {
+		return pc, false, err
+	}
+	pc.Packet = self[0]
+
+	if pc.Before, err = selectPackets(ctx, "WHERE id < $1", []any{id}, "id DESC", window); err != nil {
+		return pc, true, err
+	}
+	slices.Reverse(pc.Before)
+	if pc.After, err = selectPackets(ctx, "WHERE id > $1", []any{id}, "id ASC", window); err != nil {
+		return pc, true, err
+	}
+
+	if pc.Before == nil {
+		pc.Before = []PacketInfo{}
+	}
+	if pc.After == nil {
+		pc.After = []PacketInfo{}
+	}
+	return pc, true, nil
+}
+
+// handlePacketContextAPI serves /api/packets/{id}/context: a packet with the
+// ones recorded just before and after it, for investigating what happened
+// around it.
+func handlePacketContextAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		http.Error(w, fmt.Sprintf("invalid packet id %q", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+	window := defaultContextWindow
+	if s := r.URL.Query().Get("window"); s != "" {
+		window, err = strconv.Atoi(s)
+		if err != nil || window <= 0 {
+			http.Error(w, fmt.Sprintf("invalid window %q", s), http.StatusBadRequest)
+			return
+		}
+		window = min(window, maxContextWindow)
+	}
+
+	pc, found, err := getPacketContext(ctx, id, window)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+	if !found {
+		http.Error(w, fmt.Sprintf("Packet %d not found", id), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, r, pc)
+}
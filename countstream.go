@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// countStreamInterval is how often /api/stream/count pushes the totals
+// (STREAM_COUNT_INTERVAL).
+var countStreamInterval = 5 * time.Second
+
+type packetCounts struct {
+	Total     int64     `json:"total"`
+	Malicious int64     `json:"malicious"`
+	At        time.Time `json:"at"`
+}
+
+// sharedCounts caches the totals so every subscriber is served by one
+// COUNT(*) per interval, however many walls are watching.
+var sharedCounts struct {
+	mu     sync.Mutex
+	counts packetCounts
+}
+
+func getPacketTotals(ctx context.Context) (packetCounts, error) {
+	sharedCounts.mu.Lock()
+	defer sharedCounts.mu.Unlock()
+	fresh := time.Since(sharedCounts.counts.At) < countStreamInterval
+	countsCounters.record(fresh)
+	if fresh {
+		return sharedCounts.counts, nil
+	}
+
+	var c packetCounts
+	err := db().QueryRowContext(ctx, tagQuery(`
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE malicious > 0) FROM packet_info
+	`)).Scan(&c.Total, &c.Malicious)
+	if err != nil {
+		return c, err
+	}
+	c.At = time.Now()
+	sharedCounts.counts = c
+	return c, nil
+}
+
+// handleCountStreamAPI pushes the total and malicious packet counts over
+// SSE every countStreamInterval, for big-number displays that don't need
+// the rows.
+func handleCountStreamAPI(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := startSSE(w)
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(countStreamInterval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+		counts, err := getPacketTotals(ctx)
+		cancel()
+		if err != nil {
+			if r.Context().Err() != nil {
+				return
+			}
+			log.Printf("Database error: %v", err)
+			if writeSSE(w, flusher, "error", map[string]string{"error": "Error fetching data"}) != nil {
+				return
+			}
+		} else if writeSSE(w, flusher, "count", counts) != nil {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// runDBWatchdog probes the database every interval and, after failures
+// consecutive failed probes, replaces the connection pool with a freshly
+// opened one so that no connection from before the outage is reused.
+func runDBWatchdog(ctx context.Context, interval time.Duration, failures int) {
+	probe := func() error {
+		ctx, cancel := context.WithTimeout(ctx, interval)
+		defer cancel()
+		var one int
+		return db().QueryRowContext(ctx, tagQuery(`SELECT 1`)).Scan(&one)
+	}
+
+	failed := 0
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := probe()
+		if err == nil {
+			if failed >= failures {
+				log.Printf("DB watchdog: database reachable again after %d failed probe(s)", failed)
+			}
+			failed = 0
+			continue
+		}
+		failed++
+		log.Printf("DB watchdog: probe %d failed: %v", failed, err)
+		if failed%failures != 0 {
+			continue
+		}
+
+		stats := db().Stats()
+		log.Printf("DB watchdog: reopening connection pool after %d consecutive failures (%d open, %d in use, %d idle)",
+			failed, stats.OpenConnections, stats.InUse, stats.Idle)
+		if err := reopenDB(ctx, interval); err != nil {
+			log.Printf("DB watchdog: reopen failed, keeping the old pool: %v", err)
+		} else {
+			log.Printf("DB watchdog: pool reopened, ping succeeded")
+		}
+	}
+}
+
+// reopenDB opens and pings a new pool and swaps it in. The old pool is
+// closed in the background: Close lets queries already running on it
+// finish, and everything after the swap uses the new one. Statements
+// prepared on the old pool are flushed from the cache.
+func reopenDB(ctx context.Context, timeout time.Duration) error {
+	fresh, err := sql.Open("postgres", databaseURL())
+	if err != nil {
+		return err
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := fresh.PingContext(pingCtx); err != nil {
+		fresh.Close()
+		return err
+	}
+	old := dbConn.Swap(fresh)
+	flushStmtCache()
+	go old.Close()
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// deltaColumns are the packet fields /api/packets/delta can return, in
+// their default order.
+var deltaColumns = []struct {
+	name  string
+	value func(p PacketInfo) any
+}{
+	{"id", func(p PacketInfo) any { return p.ID }},
+	{"version", func(p PacketInfo) any { return p.Version }},
+	{"total_length", func(p PacketInfo) any { return p.TotalLength }},
+	{"flags", func(p PacketInfo) any { return p.Flags }},
+	{"ttl", func(p PacketInfo) any { return p.TTL }},
+	{"protocol", func(p PacketInfo) any { return p.Protocol }},
+	{"header_checksum", func(p PacketInfo) any { return p.HeaderChecksum }},
+	{"source_ip", func(p PacketInfo) any { return p.SourceIP }},
+	{"destination_ip", func(p PacketInfo) any { return p.DestinationIP }},
+	{"malicious", func(p PacketInfo) any { return p.Malicious }},
+	{"suspicious", func(p PacketInfo) any { return p.Suspicious }},
+	{"harmless", func(p PacketInfo) any { return p.Harmless }},
+	{"undetected", func(p PacketInfo) any { return p.Undetected }},
+	{"scan_date", func(p PacketInfo) any { return p.ScanDate }},
+	{"checked_at", func(p PacketInfo) any { return p.CheckedAt }},
+	{"source_sensor", func(p PacketInfo) any { return p.SourceSensor }},
+}
+
+// packetDelta is the columnar form of a packet page: one array of values
+// per row, in the order of Columns.
+type packetDelta struct {
+	Columns []string `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+	Cursor  string   `json:"cursor"`
+}
+
+// parseDeltaColumns selects columns from ?columns=a,b,c, or all of them.
+func parseDeltaColumns(s string) ([]int, error) {
+	if s == "" {
+		idx := make([]int, len(deltaColumns))
+		for i := range idx {
+			idx[i] = i
+		}
+		return idx, nil
+	}
+	var idx []int
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		i := -1
+		for j, c := range deltaColumns {
+			if c.name == name {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		idx = append(idx, i)
+	}
+	return idx, nil
+}
+
+// handlePacketsDeltaAPI is the cursor polling mode of /api/packets in a
+// compact columnar encoding, for clients that poll often. The cursor to
+// continue from is in the body rather than a header.
+func handlePacketsDeltaAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	filter, err := parsePacketFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.Cursor == nil {
+		http.Error(w, "cursor is required (empty to start from the beginning)", http.StatusBadRequest)
+		return
+	}
+	columns, err := parseDeltaColumns(r.URL.Query().Get("columns"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter.Settled = true
+	packets, err := queryPackets(ctx, filter, "checked_at, id")
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	delta := packetDelta{Columns: make([]string, len(columns)), Rows: make([][]any, len(packets))}
+	for i, c := range columns {
+		delta.Columns[i] = deltaColumns[c].name
+		if jsonCamelCase {
+			// Column names are values, so writeJSON leaves them alone.
+			delta.Columns[i] = snakeToCamel(delta.Columns[i])
+		}
+	}
+	for i, p := range packets {
+		row := make([]any, len(columns))
+		for j, c := range columns {
+			row[j] = deltaColumns[c].value(p)
+		}
+		delta.Rows[i] = row
+	}
+	delta.Cursor = encodeCursor(nextCursor(filter, packets))
+
+	writeJSON(w, r, delta)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// dsnFromEnv assembles a key/value connection string from DB_HOST, DB_PORT,
+// DB_NAME, DB_USER, DB_PASSWORD and DB_SSLMODE, skipping unset ones.
+func dsnFromEnv() string {
+	params := []struct{ key, env string }{
+		{"host", "DB_HOST"},
+		{"port", "DB_PORT"},
+		{"dbname", "DB_NAME"},
+		{"user", "DB_USER"},
+		{"password", "DB_PASSWORD"},
+		{"sslmode", "DB_SSLMODE"},
+	}
+
+	var parts []string
+	for _, p := range params {
+		if v := os.Getenv(p.env); v != "" {
+			parts = append(parts, p.key+"="+quoteDSNValue(v))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteDSNValue quotes a value per libpq key/value syntax: single quotes
+// around values that are empty or contain spaces, quotes or backslashes,
+// with ' and \ backslash-escaped.
+func quoteDSNValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
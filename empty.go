@@ -0,0 +1,19 @@
+package main
+
+import "context"
+
+// emptyDBHint makes /api/packets answer {"data":[],"empty":true} instead of
+// [] while packet_info has no rows at all, so clients can tell a fresh
+// install from a filter that matches nothing (EMPTY_DB_HINT).
+var emptyDBHint bool
+
+type emptyPackets struct {
+	Data  []PacketInfo `json:"data"`
+	Empty bool         `json:"empty"`
+}
+
+func packetTableEmpty(ctx context.Context) (bool, error) {
+	var exists bool
+	err := db().QueryRowContext(ctx, tagQuery(`SELECT EXISTS (SELECT 1 FROM packet_info)`)).Scan(&exists)
+	return !exists, err
+}
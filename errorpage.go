@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+)
+
+// errorTemplatePath optionally overrides the embedded error page (ERROR_TEMPLATE).
+var errorTemplatePath string
+
+type errorPageData struct {
+	Status  int
+	Title   string
+	Message string
+}
+
+func loadErrorTemplate() (*template.Template, error) {
+	if errorTemplatePath != "" {
+		return template.ParseFiles(errorTemplatePath)
+	}
+	return template.ParseFS(templateFS, "templates/error.html")
+}
+
+// renderErrorPage writes a styled HTML error page, falling back to plain
+// text if the error template itself can't be rendered.
+func renderErrorPage(w http.ResponseWriter, status int, title, message string) {
+	tmpl, err := loadErrorTemplate()
+	var buf bytes.Buffer
+	if err == nil {
+		err = tmpl.Execute(&buf, errorPageData{Status: status, Title: title, Message: message})
+	}
+	if err != nil {
+		log.Printf("Error template error: %v", err)
+		http.Error(w, title, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// dataETag is a strong ETag for a page rendered from data with the given
+// embedded templates. Templates are compiled into the binary, so the tag
+// changes exactly when the data or the build does.
+func dataETag(data any, templates ...string) (string, error) {
+	h := sha256.New()
+	for _, name := range templates {
+		t, err := templateFS.ReadFile(name)
+		if err != nil {
+			return "", err
+		}
+		h.Write(t)
+	}
+	if err := json.NewEncoder(h).Encode(data); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`, nil
+}
+
+// etagMatches reports whether the request's If-None-Match lists etag.
+func etagMatches(r *http.Request, etag string) bool {
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// setETag marks a successful response with etag. Cache-Control: no-cache
+// makes browsers revalidate on every load instead of trusting a cached
+// copy.
+func setETag(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// handleWiresharkCSV exports packets in the column layout of Wireshark's
+// "Export Packet Dissections > As CSV": No., Time, Source, Destination,
+// Protocol, Length, Info. Time is seconds relative to the first packet.
+func handleWiresharkCSV(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	filter, err := parsePacketFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	packets, err := getPackets(ctx, filter)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+	slices.Reverse(packets)
+	redactForExport(packets)
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="packets.csv"`)
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"No.", "Time", "Source", "Destination", "Protocol", "Length", "Info"})
+	for i, p := range packets {
+		elapsed := p.CheckedAt.Sub(packets[0].CheckedAt).Seconds()
+		cw.Write([]string{
+			strconv.Itoa(i + 1),
+			strconv.FormatFloat(elapsed, 'f', 6, 64),
+			p.SourceIP,
+			p.DestinationIP,
+			p.Protocol,
+			strconv.FormatInt(p.TotalLength, 10),
+			wiresharkInfo(p),
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Printf("CSV write error: %v", err)
+	}
+}
+
+func wiresharkInfo(p PacketInfo) string {
+	info := []string{fmt.Sprintf("ID=%d", p.ID), fmt.Sprintf("TTL=%d", p.TTL)}
+	if p.Flags != "" {
+		info = append(info, "Flags="+p.Flags)
+	}
+	if p.Malicious > 0 || p.Suspicious > 0 {
+		info = append(info, fmt.Sprintf("Malicious=%d Suspicious=%d", p.Malicious, p.Suspicious))
+	}
+	return strings.Join(info, " ")
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// collapsibleColumns are the columns ?collapse= may name.
+var collapsibleColumns = map[string]bool{"source_ip": true, "destination_ip": true}
+
+const (
+	defaultPacketLimit = 1000
+	maxPacketLimit     = 1000
+
+	// maxIPListLength bounds the IN (...) list built from ?source_ip=a,b,c.
+	maxIPListLength = 100
+)
+
+// packetFilter holds the row selection for packet queries.
+type packetFilter struct {
+	AfterID        int
+	BeforeID       int
+	CheckedFrom    *time.Time
+	CheckedTo      *time.Time
+	HeaderChecksum *int
+	Protocol       string
+	Sensor         string
+	SourceIPs      []string
+	DestinationIPs []string
+
+	// IPScope is "private" (RFC 1918) or "public"; IPScopeOn says which
+	// address it applies to: "source", "destination" or "both".
+	IPScope   string
+	IPScopeOn string
+
+	// ExcludeUndetectedOnly drops packets no engine had an opinion on.
+	ExcludeUndetectedOnly bool
+
+	// VerdictSince drops packets whose threat verdict (scan_date) is older.
+	// ExcludeUnscanned drops packets never scanned (NULL scan_date).
+	VerdictSince     *time.Time
+	ExcludeUnscanned bool
+
+	// MaliciousOnly keeps packets with a malicious verdict;
+	// ExcludeAcknowledged drops ones already reviewed. Handlers set these,
+	// not query parameters.
+	MaliciousOnly       bool
+	ExcludeAcknowledged bool
+
+	// Collapse, when set, returns only the newest packet per distinct value
+	// of this column (one of collapsibleColumns).
+	Collapse string
+
+	// Cursor switches to polling mode: rows strictly after it in
+	// (checked_at, id) order, oldest first.
+	Cursor *packetCursor
+
+	// Settled stops a poll (after_id or cursor) before the first row that
+	// may still have an unseen row ahead of it in the poll's order.
+	Settled bool
+
+	Limit int
+}
+
+// unsettledSQL matches rows of packet_info u committed by a transaction no
+// older than the oldest one still running. That one may yet commit rows
+// (with a lower id, or an earlier checked_at since the column defaults to
+// its start time) that a poll must not step over, so Settled polls stop at
+// the first such row and pick it up once the older transaction has ended.
+const unsettledSQL = `age(u.xmin) <= age((pg_snapshot_xmin(pg_current_snapshot())::text::bigint % 4294967296)::text::xid)`
+
+// where renders the filter as a SQL WHERE clause with positional args.
+func (f packetFilter) where() (string, []any) {
+	var conds []string
+	var args []any
+
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.AfterID > 0 {
+		add("id > $%d", f.AfterID)
+	}
+	if f.BeforeID > 0 {
+		add("id < $%d", f.BeforeID)
+	}
+	if f.Cursor != nil {
+		args = append(args, f.Cursor.CheckedAt, f.Cursor.ID)
+		conds = append(conds, fmt.Sprintf("(checked_at, id) > ($%d, $%d)", len(args)-1, len(args)))
+	}
+	if f.Settled && f.Cursor != nil {
+		args = append(args, f.Cursor.CheckedAt, f.Cursor.ID)
+		conds = append(conds, fmt.Sprintf(`COALESCE((checked_at, id) < (
+			SELECT u.checked_at, u.id FROM packet_info u
+			WHERE (u.checked_at, u.id) > ($%d, $%d) AND %s
+			ORDER BY u.checked_at, u.id LIMIT 1), true)`, len(args)-1, len(args), unsettledSQL))
+	} else if f.Settled && f.AfterID > 0 {
+		add("COALESCE(id < (SELECT MIN(u.id) FROM packet_info u WHERE u.id > $%d AND "+unsettledSQL+"), true)", f.AfterID)
+	}
+	if f.CheckedFrom != nil {
+		add("checked_at >= $%d", *f.CheckedFrom)
+	}
+	if f.CheckedTo != nil {
+		add("checked_at <= $%d", *f.CheckedTo)
+	}
+	if f.HeaderChecksum != nil {
+		add("header_checksum = $%d", *f.HeaderChecksum)
+	}
+
+	if f.Protocol != "" {
+		add("protocol = $%d", f.Protocol)
+	}
+	if f.Sensor != "" {
+		add("source_sensor = $%d", f.Sensor)
+	}
+
+	addIn := func(column string, values []string) {
+		placeholders := make([]string, len(values))
+		for i, v := range values {
+			args = append(args, v)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conds = append(conds, column+" IN ("+strings.Join(placeholders, ", ")+")")
+	}
+
+	if len(f.SourceIPs) > 0 {
+		addIn("source_ip", f.SourceIPs)
+	}
+	if len(f.DestinationIPs) > 0 {
+		addIn("destination_ip", f.DestinationIPs)
+	}
+
+	if f.IPScope != "" {
+		columns := []string{"source_ip"}
+		switch f.IPScopeOn {
+		case "destination":
+			columns = []string{"destination_ip"}
+		case "both":
+			columns = []string{"source_ip", "destination_ip"}
+		}
+		for _, col := range columns {
+			cond := rfc1918Condition(col)
+			if f.IPScope == "public" {
+				cond = "NOT " + cond
+			}
+			conds = append(conds, cond)
+		}
+	}
+	if f.ExcludeUndetectedOnly {
+		conds = append(conds, "NOT (malicious = 0 AND suspicious = 0 AND harmless = 0 AND undetected > 0)")
+	}
+
+	if f.MaliciousOnly {
+		conds = append(conds, "malicious > 0")
+	}
+	if f.ExcludeAcknowledged {
+		conds = append(conds, "NOT acknowledged")
+	}
+	if f.VerdictSince != nil {
+		if f.ExcludeUnscanned {
+			add(scanDateSQL+" >= $%d", *f.VerdictSince)
+		} else {
+			add("("+scanDateSQL+" >= $%d OR scan_date IS NULL)", *f.VerdictSince)
+		}
+	} else if f.ExcludeUnscanned {
+		conds = append(conds, "scan_date IS NOT NULL")
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// listingWhere is where() as the packet listing applies it, including
+// ?collapse=.
+func (f packetFilter) listingWhere() (string, []any) {
+	where, args := f.where()
+	if f.Collapse != "" {
+		// Keep only the newest matching packet per distinct column value.
+		where = fmt.Sprintf(`WHERE id IN (SELECT DISTINCT ON (%[1]s) id FROM packet_info %[2]s ORDER BY %[1]s, id DESC)`,
+			f.Collapse, where)
+	}
+	return where, args
+}
+
+func parsePacketFilter(r *http.Request) (packetFilter, error) {
+	q := r.URL.Query()
+	f := packetFilter{Limit: defaultPacketLimit}
+
+	if idStr := q.Get("after_id"); idStr != "" {
+		if id, err := strconv.Atoi(idStr); err == nil {
+			f.AfterID = id
+		}
+	}
+	if s := q.Get("before_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id <= 0 {
+			return f, fmt.Errorf("invalid before_id %q", s)
+		}
+		f.BeforeID = id
+	}
+	if q.Has("cursor") {
+		if f.AfterID > 0 || f.BeforeID > 0 {
+			return f, fmt.Errorf("cursor cannot be combined with after_id or before_id")
+		}
+		c, err := decodeCursor(q.Get("cursor"))
+		if err != nil {
+			return f, err
+		}
+		f.Cursor = &c
+	}
+	for key, dst := range map[string]**time.Time{"from": &f.CheckedFrom, "to": &f.CheckedTo} {
+		if s := q.Get(key); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return f, fmt.Errorf("invalid %s %q (want RFC 3339)", key, s)
+			}
+			*dst = &t
+		}
+	}
+	if f.CheckedFrom != nil || f.CheckedTo != nil {
+		if err := checkQueryWindow(r, "from/to", rangeSpan(f.CheckedFrom, f.CheckedTo)); err != nil {
+			return f, err
+		}
+	}
+	if s := q.Get("header_checksum"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return f, fmt.Errorf("invalid header_checksum %q", s)
+		}
+		f.HeaderChecksum = &n
+	}
+	f.Protocol = q.Get("protocol")
+	f.Sensor = q.Get("sensor")
+	switch scope := q.Get("ip_scope"); scope {
+	case "", "private", "public":
+		f.IPScope = scope
+	default:
+		return f, fmt.Errorf("invalid ip_scope %q (want private or public)", scope)
+	}
+	switch on := q.Get("ip_scope_on"); on {
+	case "", "source", "destination", "both":
+		f.IPScopeOn = on
+	default:
+		return f, fmt.Errorf("invalid ip_scope_on %q (want source, destination or both)", on)
+	}
+	f.ExcludeUndetectedOnly, _ = strconv.ParseBool(q.Get("exclude_undetected_only"))
+	if s := q.Get("verdict_age_max"); s != "" {
+		age, err := parseAge(s)
+		if err != nil {
+			return f, fmt.Errorf("invalid verdict_age_max %q", s)
+		}
+		since := time.Now().Add(-age)
+		f.VerdictSince = &since
+	}
+	// Unscanned packets have no verdict to be stale, so they're kept unless
+	// a verdict age is asked for.
+	f.ExcludeUnscanned = f.VerdictSince != nil
+	if s := q.Get("include_unscanned"); s != "" {
+		include, err := strconv.ParseBool(s)
+		if err != nil {
+			return f, fmt.Errorf("invalid include_unscanned %q", s)
+		}
+		f.ExcludeUnscanned = !include
+	}
+	if c := q.Get("collapse"); c != "" {
+		if !collapsibleColumns[c] {
+			return f, fmt.Errorf("invalid collapse %q (want source_ip or destination_ip)", c)
+		}
+		if f.Cursor != nil {
+			return f, fmt.Errorf("collapse cannot be combined with cursor")
+		}
+		f.Collapse = c
+	}
+
+	var err error
+	if f.SourceIPs, err = parseIPList(q.Get("source_ip"), "source_ip"); err != nil {
+		return f, err
+	}
+	if f.DestinationIPs, err = parseIPList(q.Get("destination_ip"), "destination_ip"); err != nil {
+		return f, err
+	}
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return f, fmt.Errorf("invalid limit %q", s)
+		}
+		f.Limit = min(n, maxPacketLimit)
+	}
+
+	return f, nil
+}
+
+// durationParam reads a positive Go duration query parameter.
+func durationParam(r *http.Request, key string, def time.Duration) (time.Duration, error) {
+	s := r.URL.Query().Get(key)
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, s)
+	}
+	return d, nil
+}
+
+// parseAge parses a positive Go duration, also accepting whole days ("7d").
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+// parseIPList splits a comma-separated list of IP addresses.
+func parseIPList(s, key string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var ips []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if net.ParseIP(part) == nil {
+			return nil, fmt.Errorf("invalid %s %q", key, part)
+		}
+		ips = append(ips, part)
+	}
+	if len(ips) > maxIPListLength {
+		return nil, fmt.Errorf("too many values for %s (max %d)", key, maxIPListLength)
+	}
+	return ips, nil
+}
+
+// rfc1918Condition matches rows where column holds an RFC 1918 private address.
+func rfc1918Condition(column string) string {
+	return fmt.Sprintf("(%[1]s::inet <<= '10.0.0.0/8' OR %[1]s::inet <<= '172.16.0.0/12' OR %[1]s::inet <<= '192.168.0.0/16')", column)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// TCPFlags is the decoded form of the stored flags string for TCP packets.
+type TCPFlags struct {
+	SYN bool `json:"syn"`
+	ACK bool `json:"ack"`
+	FIN bool `json:"fin"`
+	RST bool `json:"rst"`
+	PSH bool `json:"psh"`
+	URG bool `json:"urg"`
+	ECE bool `json:"ece"`
+	CWR bool `json:"cwr"`
+}
+
+// Bit values as they appear in the TCP header.
+const (
+	tcpFIN = 1 << iota
+	tcpSYN
+	tcpRST
+	tcpPSH
+	tcpACK
+	tcpURG
+	tcpECE
+	tcpCWR
+)
+
+var tcpFlagNames = map[string]int{
+	"FIN": tcpFIN, "SYN": tcpSYN, "RST": tcpRST, "PSH": tcpPSH,
+	"ACK": tcpACK, "URG": tcpURG, "ECE": tcpECE, "CWR": tcpCWR,
+}
+
+// Single-letter notation as produced by scapy/tcpdump (e.g. "SA", "FPU").
+var tcpFlagLetters = map[rune]int{
+	'F': tcpFIN, 'S': tcpSYN, 'R': tcpRST, 'P': tcpPSH,
+	'A': tcpACK, 'U': tcpURG, 'E': tcpECE, 'C': tcpCWR,
+}
+
+func isTCP(protocol string) bool {
+	return strings.EqualFold(protocol, "TCP") || protocol == "6"
+}
+
+// parseFlags decodes a stored TCP flags value. It accepts a numeric bitmask
+// ("18", "0x12"), flag names ("SYN,ACK", "SYN|ACK", "[SYN ACK]") or letter
+// notation ("SA"). Empty or unrecognised values yield nil.
+func parseFlags(raw string) *TCPFlags {
+	raw = strings.Trim(strings.TrimSpace(raw), "[]")
+	if raw == "" {
+		return nil
+	}
+
+	bits, ok := parseFlagBits(raw)
+	if !ok {
+		return nil
+	}
+
+	return &TCPFlags{
+		SYN: bits&tcpSYN != 0,
+		ACK: bits&tcpACK != 0,
+		FIN: bits&tcpFIN != 0,
+		RST: bits&tcpRST != 0,
+		PSH: bits&tcpPSH != 0,
+		URG: bits&tcpURG != 0,
+		ECE: bits&tcpECE != 0,
+		CWR: bits&tcpCWR != 0,
+	}
+}
+
+func parseFlagBits(raw string) (int, bool) {
+	if n, err := strconv.ParseInt(raw, 0, 16); err == nil {
+		return int(n) & 0xff, n >= 0
+	}
+
+	tokens := strings.FieldsFunc(strings.ToUpper(raw), func(r rune) bool {
+		return r == ',' || r == '|' || r == ' ' || r == '+'
+	})
+
+	bits := 0
+	for _, tok := range tokens {
+		if bit, ok := tcpFlagNames[tok]; ok {
+			bits |= bit
+			continue
+		}
+		for _, c := range tok {
+			bit, ok := tcpFlagLetters[c]
+			if !ok {
+				return 0, false
+			}
+			bits |= bit
+		}
+	}
+
+	return bits, len(tokens) > 0
+}
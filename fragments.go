@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+)
+
+// handlePacketRowsFragment renders only the <tr> rows for the packet table,
+// for HTMX-style polling (hx-get + hx-swap="afterbegin").
+func handlePacketRowsFragment(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	tmpl, err := template.ParseFS(templateFS, "templates/packet_rows.html")
+	if err != nil {
+		http.Error(w, "Error loading template", http.StatusInternalServerError)
+		log.Printf("Template error: %v", err)
+		return
+	}
+
+	filter, err := parsePacketFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	packets, err := getPackets(ctx, filter)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "packet-rows", packets); err != nil {
+		http.Error(w, "Error rendering fragment", http.StatusInternalServerError)
+		log.Printf("Template execute error: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+)
+
+type freshness struct {
+	LatestCheckedAt *time.Time `json:"latest_checked_at"`
+	AgeSeconds      *float64   `json:"age_seconds"` // null when there are no packets
+}
+
+// getLatestCheckedAt returns the newest checked_at, which an index on the
+// column (AUTO_INDEX) answers without a scan.
+func getLatestCheckedAt(ctx context.Context) (sql.NullTime, error) {
+	var latest sql.NullTime
+	err := db().QueryRowContext(ctx, tagQuery(`SELECT MAX(checked_at) FROM packet_info`)).Scan(&latest)
+	return latest, err
+}
+
+func handleFreshnessAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	latest, err := getLatestCheckedAt(ctx)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	var f freshness
+	if latest.Valid {
+		age := time.Since(latest.Time).Seconds()
+		f.LatestCheckedAt = &latest.Time
+		f.AgeSeconds = &age
+	}
+	writeJSON(w, r, f)
+}
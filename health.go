@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// inFlight counts requests currently being served.
+var inFlight atomic.Int64
+
+func trackInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight.Add(1)
+		defer inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+type healthStatus struct {
+	Status   string `json:"status"`
+	Database string `json:"database"`
+	InFlight int64  `json:"in_flight"`
+	Streams  int    `json:"streams"`
+
+	// Maintenance is set while every other route answers 503.
+	Maintenance bool `json:"maintenance,omitempty"`
+}
+
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	status := healthStatus{Status: "ok", Database: "ok", InFlight: inFlight.Load(), Streams: openStreams(), Maintenance: inMaintenance()}
+	code := http.StatusOK
+	if err := db().PingContext(ctx); err != nil {
+		status.Status = "degraded"
+		status.Database = err.Error()
+		code = http.StatusServiceUnavailable
+	}
+
+	writeJSONStatus(w, r, code, status)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// filterHint flags a filter value that matches nothing in packet_info, so
+// clients can tell a typo from an empty time range.
+type filterHint struct {
+	Param string `json:"param"`
+	Value string `json:"value"`
+	Hint  string `json:"hint"`
+}
+
+type packetsWithHints struct {
+	Packets []PacketInfo `json:"packets"`
+	Hints   []filterHint `json:"hints"`
+}
+
+// missingValues returns the values that never occur in column. column must
+// be a constant, never user input.
+func missingValues(ctx context.Context, column string, values []string) ([]string, error) {
+	query := fmt.Sprintf(`SELECT DISTINCT %[1]s FROM packet_info WHERE %[1]s = ANY($1)`, column)
+	rows, err := db().QueryContext(ctx, tagQuery(query), pq.Array(values))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := map[string]bool{}
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		seen[v] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, v := range values {
+		if !seen[v] {
+			missing = append(missing, v)
+		}
+	}
+	return missing, nil
+}
+
+// filterHints checks each value-matching filter in f against the whole
+// dataset, ignoring the other filters.
+func filterHints(ctx context.Context, f packetFilter) ([]filterHint, error) {
+	hints := []filterHint{}
+	check := func(param, column string, values []string) error {
+		if len(values) == 0 {
+			return nil
+		}
+		missing, err := missingValues(ctx, column, values)
+		if err != nil {
+			return err
+		}
+		for _, v := range missing {
+			hints = append(hints, filterHint{Param: param, Value: v, Hint: "no packets have this " + param})
+		}
+		return nil
+	}
+
+	if f.Protocol != "" {
+		if err := check("protocol", "protocol", []string{f.Protocol}); err != nil {
+			return nil, err
+		}
+	}
+	if err := check("source_ip", "source_ip", f.SourceIPs); err != nil {
+		return nil, err
+	}
+	if err := check("destination_ip", "destination_ip", f.DestinationIPs); err != nil {
+		return nil, err
+	}
+	if f.HeaderChecksum != nil {
+		var exists bool
+		err := db().QueryRowContext(ctx, tagQuery(`SELECT EXISTS (SELECT 1 FROM packet_info WHERE header_checksum = $1)`), *f.HeaderChecksum).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			hints = append(hints, filterHint{Param: "header_checksum", Value: fmt.Sprint(*f.HeaderChecksum), Hint: "no packets have this header_checksum"})
+		}
+	}
+
+	return hints, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// autoIndexes covers the columns the API filters on most. Names are fixed so
+// that IF NOT EXISTS makes reruns no-ops.
+var autoIndexes = []struct {
+	name, column string
+}{
+	{"packet_info_source_ip_idx", "source_ip"},
+	{"packet_info_checked_at_idx", "checked_at"},
+	{"packet_info_protocol_idx", "protocol"},
+}
+
+// createIndexes creates any missing autoIndexes (AUTO_INDEX). Building an
+// index on a large table can take a while, so no query timeout applies.
+func createIndexes(ctx context.Context) error {
+	for _, idx := range autoIndexes {
+		start := time.Now()
+		query := "CREATE INDEX IF NOT EXISTS " + idx.name + " ON packet_info (" + idx.column + ")"
+		if _, err := db().ExecContext(ctx, tagQuery(query)); err != nil {
+			return err
+		}
+		log.Printf("Index %s on packet_info(%s) ready (%s)", idx.name, idx.column, time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
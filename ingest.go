@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"reflect"
+	"strings"
+)
+
+// ingestToken authorises sensors posting to /api/ingest (INGEST_TOKEN);
+// they name themselves with X-Sensor-ID. sensorTokens maps per-sensor
+// tokens to the sensor they identify (INGEST_SENSOR_TOKENS). With neither
+// set, ingest is disabled.
+var (
+	ingestToken  string
+	sensorTokens map[string]string
+)
+
+// maxSensorIDLength bounds source_sensor values.
+const maxSensorIDLength = 64
+
+// parseSensorTokens parses "sensor-a=token1,sensor-b=token2".
+func parseSensorTokens(s string) map[string]string {
+	tokens := map[string]string{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		sensor, token, ok := strings.Cut(part, "=")
+		sensor, token = strings.TrimSpace(sensor), strings.TrimSpace(token)
+		if !ok || sensor == "" || token == "" || len(sensor) > maxSensorIDLength {
+			log.Printf("Ignoring invalid sensor token entry for %q", sensor)
+			continue
+		}
+		tokens[token] = sensor
+	}
+	return tokens
+}
+
+// ingestSensor authenticates an ingest request and returns the sensor that
+// sent it. A per-sensor token decides the sensor; with the shared token it
+// comes from X-Sensor-ID and may be empty.
+func ingestSensor(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return "", false
+	}
+	for t, sensor := range sensorTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return sensor, true
+		}
+	}
+	if ingestToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(ingestToken)) == 1 {
+		return strings.TrimSpace(r.Header.Get("X-Sensor-ID")), true
+	}
+	return "", false
+}
+
+// maxIngestBatch bounds how many packets one ingest request may carry.
+const maxIngestBatch = 1000
+
+// fieldError describes one invalid field of an ingested packet. Index is the
+// packet's position in the submitted batch.
+type fieldError struct {
+	Index int    `json:"index"`
+	Field string `json:"field"`
+	Msg   string `json:"msg"`
+}
+
+type validationErrors struct {
+	Errors []fieldError `json:"errors"`
+}
+
+// validatePacket checks the fields a sensor must supply and the ranges the
+// IPv4/IPv6 headers allow.
+func validatePacket(i int, p PacketInfo) []fieldError {
+	var errs []fieldError
+	add := func(field, format string, args ...any) {
+		errs = append(errs, fieldError{Index: i, Field: field, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if strings.TrimSpace(p.Version) == "" {
+		add("version", "is required")
+	}
+	if strings.TrimSpace(p.Protocol) == "" {
+		add("protocol", "is required")
+	}
+	checkIP := func(field, ip string) {
+		if ip == "" {
+			add(field, "is required")
+		} else if _, err := netip.ParseAddr(ip); err != nil {
+			add(field, "must be a valid IPv4 or IPv6 address")
+		}
+	}
+	checkIP("source_ip", p.SourceIP)
+	checkIP("destination_ip", p.DestinationIP)
+	if p.TTL < 0 || p.TTL > 255 {
+		add("ttl", "must be 0-255")
+	}
+	if p.TotalLength < 0 || p.TotalLength > maxTotalLength {
+		add("total_length", "must be 0-%d", maxTotalLength)
+	}
+	if p.HeaderChecksum < 0 || p.HeaderChecksum > 0xffff {
+		add("header_checksum", "must be 0-65535")
+	}
+	checkCount := func(field string, n int) {
+		if n < 0 {
+			add(field, "must not be negative")
+		}
+	}
+	checkCount("malicious", p.Malicious)
+	checkCount("suspicious", p.Suspicious)
+	checkCount("harmless", p.Harmless)
+	checkCount("undetected", p.Undetected)
+	if _, err := parseSampleTime(p.ScanDate); err != nil {
+		add("scan_date", "must be a date (2006-01-02) or RFC 3339 timestamp")
+	}
+
+	return errs
+}
+
+// decodeIngestBody accepts either a single packet object or an array of them.
+// Values of the wrong JSON type are reported like validation errors, one
+// per packet.
+func decodeIngestBody(w http.ResponseWriter, r *http.Request) ([]PacketInfo, bool) {
+	var raw json.RawMessage
+	if !decodeJSONBody(w, r, &raw) {
+		return nil, false
+	}
+
+	elems := []json.RawMessage{raw}
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		if err := json.Unmarshal(raw, &elems); err != nil {
+			http.Error(w, "Invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+	}
+
+	packets := make([]PacketInfo, len(elems))
+	verrs := validationErrors{Errors: []fieldError{}}
+	for i, elem := range elems {
+		err := json.Unmarshal(elem, &packets[i])
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case err == nil:
+		case errors.As(err, &typeErr):
+			msg := "must be " + jsonTypeName(typeErr.Type)
+			if typeErr.Field == "" {
+				msg = "packet must be a JSON object"
+			}
+			verrs.Errors = append(verrs.Errors, fieldError{Index: i, Field: typeErr.Field, Msg: msg})
+		default:
+			http.Error(w, "Invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	if len(verrs.Errors) > 0 {
+		writeJSONStatus(w, r, http.StatusBadRequest, verrs)
+		return nil, false
+	}
+	return packets, true
+}
+
+// jsonTypeName names the JSON type a Go value decodes from.
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
+// handleIngestAPI inserts packets posted by a sensor. Invalid batches are
+// rejected as a whole with a 400 listing every offending field.
+func handleIngestAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ingestToken == "" && len(sensorTokens) == 0 {
+		http.Error(w, "Ingest is disabled (INGEST_TOKEN not set)", http.StatusForbidden)
+		return
+	}
+	sensor, ok := ingestSensor(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="ingest"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if len(sensor) > maxSensorIDLength {
+		http.Error(w, fmt.Sprintf("X-Sensor-ID longer than %d characters", maxSensorIDLength), http.StatusBadRequest)
+		return
+	}
+
+	packets, ok := decodeIngestBody(w, r)
+	if !ok {
+		return
+	}
+	if len(packets) == 0 {
+		http.Error(w, "No packets submitted", http.StatusBadRequest)
+		return
+	}
+	if len(packets) > maxIngestBatch {
+		http.Error(w, fmt.Sprintf("At most %d packets per request", maxIngestBatch), http.StatusBadRequest)
+		return
+	}
+
+	verrs := validationErrors{Errors: []fieldError{}}
+	for i, p := range packets {
+		verrs.Errors = append(verrs.Errors, validatePacket(i, p)...)
+		// The sensor comes from the credentials, never the body.
+		packets[i].SourceSensor = sensor
+	}
+	if len(verrs.Errors) > 0 {
+		writeJSONStatus(w, r, http.StatusBadRequest, verrs)
+		return
+	}
+
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	n, err := insertPackets(ctx, packets)
+	if err != nil {
+		http.Error(w, "Error inserting data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	writeJSONStatus(w, r, http.StatusCreated, map[string]int{"inserted": n})
+}
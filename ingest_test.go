@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecodeIngestBodyTypeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		body string
+		want []fieldError // nil: decodes fine
+	}{
+		{`{"ttl": 64, "protocol": "TCP"}`, nil},
+		{`[{"ttl": 64}, {"ttl": 1}]`, nil},
+		{`{"ttl": "x"}`, []fieldError{{Index: 0, Field: "ttl", Msg: "must be an integer"}}},
+		{`[{"ttl": 64}, {"ttl": "x"}, {"protocol": 6}]`, []fieldError{
+			{Index: 1, Field: "ttl", Msg: "must be an integer"},
+			{Index: 2, Field: "protocol", Msg: "must be a string"},
+		}},
+		{`[{"ttl": 64}, 5]`, []fieldError{{Index: 1, Msg: "packet must be a JSON object"}}},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/ingest", strings.NewReader(tc.body))
+		_, ok := decodeIngestBody(w, r)
+		if tc.want == nil {
+			if !ok {
+				t.Errorf("%s: rejected: %s", tc.body, w.Body)
+			}
+			continue
+		}
+		if ok || w.Code != http.StatusBadRequest {
+			t.Errorf("%s: ok=%v code=%d, want a 400", tc.body, ok, w.Code)
+			continue
+		}
+		var got validationErrors
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Errorf("%s: body %q is not the errors JSON: %v", tc.body, w.Body, err)
+			continue
+		}
+		if !slices.Equal(got.Errors, tc.want) {
+			t.Errorf("%s: errors = %+v, want %+v", tc.body, got.Errors, tc.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// runIngestGapCheck alerts when no packet has been checked for longer than
+// maxGap, e.g. because a sensor or the capture pipeline died, and again
+// once packets arrive again. An empty table is not a gap: nothing has been
+// ingested yet.
+func runIngestGapCheck(ctx context.Context, maxGap, interval time.Duration) {
+	alerting := false
+
+	check := func() error {
+		qctx, cancel := context.WithTimeout(ctx, queryTimeout)
+		defer cancel()
+
+		latest, err := getLatestCheckedAt(qctx)
+		if err != nil || !latest.Valid {
+			return err
+		}
+		gap := time.Since(latest.Time)
+		stalled := latest.Time.UTC().Format(time.RFC3339)
+
+		// alerting only flips once an alert is sent, so a failed one is
+		// retried on the next check.
+		switch {
+		case gap > maxGap && !alerting:
+			// Keyed by when ingest stopped only, so a restart during the
+			// same gap doesn't alert it again.
+			err := sendAlert(ctx, Alert{
+				Type:           "ingest_gap",
+				Message:        fmt.Sprintf("No packets since %s (%s, limit %s)", stalled, gap.Round(time.Second), maxGap),
+				IdempotencyKey: "ingest_gap:" + latest.Time.UTC().Format(time.RFC3339Nano),
+				Details: map[string]any{
+					"last_checked_at": latest.Time,
+					"gap_seconds":     gap.Seconds(),
+					"max_gap":         maxGap.String(),
+				},
+			})
+			alerting = err == nil
+			return err
+		case gap <= maxGap && alerting:
+			err := sendAlert(ctx, Alert{
+				Type:           "ingest_resumed",
+				Message:        fmt.Sprintf("Packets arriving again, latest at %s", stalled),
+				IdempotencyKey: "ingest_resumed:" + latest.Time.UTC().Format(time.RFC3339Nano),
+				Details:        map[string]any{"last_checked_at": latest.Time},
+			})
+			alerting = err != nil
+			return err
+		}
+		return nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := check(); err != nil {
+			log.Printf("Ingest gap check error: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
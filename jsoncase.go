@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// jsonCamelCase re-keys API output from snake_case to camelCase (JSON_CASE=camel).
+var jsonCamelCase bool
+
+func snakeToCamel(s string) string {
+	parts := strings.Split(s, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+func camelizeKeys(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, val := range v {
+			out[snakeToCamel(k)] = camelizeKeys(val)
+		}
+		return out
+	case []any:
+		for i := range v {
+			v[i] = camelizeKeys(v[i])
+		}
+		return v
+	}
+	return v
+}
+
+// toCamelCaseJSON round-trips v through JSON with all object keys camelCased.
+func toCamelCaseJSON(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic any
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return camelizeKeys(generic), nil
+}
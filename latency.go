@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencyWindow is the number of most recent samples kept per route.
+const latencyWindow = 1024
+
+type routeLatency struct {
+	count   int64
+	samples []time.Duration // ring buffer
+	next    int
+}
+
+var (
+	latencyMu sync.Mutex
+	latencies = map[string]*routeLatency{}
+)
+
+func recordLatency(route string, d time.Duration) {
+	latencyMu.Lock()
+	defer latencyMu.Unlock()
+
+	rl, ok := latencies[route]
+	if !ok {
+		rl = &routeLatency{samples: make([]time.Duration, 0, latencyWindow)}
+		latencies[route] = rl
+	}
+	rl.count++
+	if len(rl.samples) < latencyWindow {
+		rl.samples = append(rl.samples, d)
+		return
+	}
+	rl.samples[rl.next] = d
+	rl.next = (rl.next + 1) % latencyWindow
+}
+
+// trackLatency records handler duration keyed by the matched mux pattern.
+// It must wrap the ServeMux so the pattern is set once next returns.
+func trackLatency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		recordLatency(route, time.Since(start))
+	})
+}
+
+type latencySummary struct {
+	Count   int64   `json:"count"`
+	Samples int     `json:"samples"`
+	P50MS   float64 `json:"p50_ms"`
+	P90MS   float64 `json:"p90_ms"`
+	P99MS   float64 `json:"p99_ms"`
+}
+
+// percentile picks the nearest-rank value from sorted samples.
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p*float64(len(sorted))+0.5) - 1
+	idx = max(0, min(idx, len(sorted)-1))
+	return float64(sorted[idx].Microseconds()) / 1000
+}
+
+func latencySummaries() map[string]latencySummary {
+	latencyMu.Lock()
+	snapshot := make(map[string]routeLatency, len(latencies))
+	for route, rl := range latencies {
+		snapshot[route] = routeLatency{count: rl.count, samples: slices.Clone(rl.samples)}
+	}
+	latencyMu.Unlock()
+
+	out := make(map[string]latencySummary, len(snapshot))
+	for route, rl := range snapshot {
+		slices.Sort(rl.samples)
+		out[route] = latencySummary{
+			Count:   rl.count,
+			Samples: len(rl.samples),
+			P50MS:   percentile(rl.samples, 0.50),
+			P90MS:   percentile(rl.samples, 0.90),
+			P99MS:   percentile(rl.samples, 0.99),
+		}
+	}
+	return out
+}
+
+func handleAdminLatency(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, latencySummaries())
+}
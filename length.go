@@ -0,0 +1,41 @@
+package main
+
+import (
+	"log"
+	"unicode/utf8"
+)
+
+// maxTotalLength is the largest value the 16-bit IPv4 total length field
+// can hold.
+const maxTotalLength = 65535
+
+// clampedTotalLengthSQL is total_length clamped to [0, maxTotalLength] in
+// SQL, for aggregate queries, so bad rows can't skew byte sums.
+const clampedTotalLengthSQL = "LEAST(GREATEST(total_length, 0), 65535)"
+
+// clampTotalLength bounds a stored total_length to [0, maxTotalLength],
+// logging when a value had to be corrected.
+func clampTotalLength(id int, n int64) int64 {
+	if n >= 0 && n <= maxTotalLength {
+		return n
+	}
+	clamped := min(max(n, 0), maxTotalLength)
+	log.Printf("Warning: packet %d has out-of-range total_length %d, clamping to %d", id, n, clamped)
+	return clamped
+}
+
+// maxStringFieldLength bounds the version and flags strings returned from
+// the database (MAX_STRING_FIELD_LENGTH), so pathological rows from a
+// misbehaving ingest path can't bloat every response.
+var maxStringFieldLength = 128
+
+// truncateField shortens s to maxStringFieldLength runes, ending it with an
+// ellipsis and logging a warning when it had to be cut.
+func truncateField(id int, field, s string) string {
+	if utf8.RuneCountInString(s) <= maxStringFieldLength {
+		return s
+	}
+	runes := []rune(s)
+	log.Printf("Warning: packet %d has a %d-character %s, truncating to %d", id, len(runes), field, maxStringFieldLength)
+	return string(runes[:maxStringFieldLength-1]) + "…"
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestClampedTotalLengthSQL keeps the SQL bound in step with maxTotalLength.
+func TestClampedTotalLengthSQL(t *testing.T) {
+	if !strings.HasSuffix(clampedTotalLengthSQL, ", "+strconv.Itoa(maxTotalLength)+")") {
+		t.Errorf("clampedTotalLengthSQL = %q does not clamp to %d", clampedTotalLengthSQL, maxTotalLength)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// loadSample bulk-inserts packets from a .json (array of packet objects, as
+// returned by /api/packets) or .csv (header row of the same JSON keys) file.
+// IDs in the file are ignored; the database assigns new ones.
+func loadSample(ctx context.Context, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var packets []PacketInfo
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.NewDecoder(f).Decode(&packets)
+	case ".csv":
+		packets, err = readSampleCSV(f)
+	default:
+		return 0, fmt.Errorf("unsupported sample format %q (want .json or .csv)", filepath.Ext(path))
+	}
+	if err != nil {
+		return 0, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	return insertPackets(ctx, packets)
+}
+
+func insertPackets(ctx context.Context, packets []PacketInfo) (int, error) {
+	tx, err := db().BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("packet_info",
+		"version", "total_length", "flags", "ttl", "protocol", "header_checksum",
+		"source_ip", "destination_ip", "malicious", "suspicious", "harmless",
+		"undetected", "scan_date", "checked_at", "source_sensor"))
+	if err != nil {
+		return 0, err
+	}
+
+	for i, p := range packets {
+		scanDate, err := parseSampleTime(p.ScanDate)
+		if err != nil {
+			return 0, fmt.Errorf("packet %d: invalid scan_date: %w", i+1, err)
+		}
+		checkedAt := p.CheckedAt
+		if checkedAt.IsZero() {
+			checkedAt = time.Now()
+		}
+		var flags, sensor any
+		if p.Flags != "" {
+			flags = p.Flags
+		}
+		if p.SourceSensor != "" {
+			sensor = p.SourceSensor
+		}
+
+		_, err = stmt.ExecContext(ctx, p.Version, p.TotalLength, flags, p.TTL, p.Protocol,
+			p.HeaderChecksum, p.SourceIP, p.DestinationIP, p.Malicious, p.Suspicious,
+			p.Harmless, p.Undetected, scanDate, checkedAt, sensor)
+		if err != nil {
+			return 0, fmt.Errorf("packet %d: %w", i+1, err)
+		}
+	}
+
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return 0, err
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, err
+	}
+	return len(packets), tx.Commit()
+}
+
+// parseSampleTime accepts an empty string (NULL), a date or an RFC 3339 timestamp.
+func parseSampleTime(s string) (any, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func readSampleCSV(r io.Reader) ([]PacketInfo, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := map[string]int{}
+	for i, name := range records[0] {
+		header[strings.TrimSpace(name)] = i
+	}
+
+	packets := make([]PacketInfo, 0, len(records)-1)
+	for line, rec := range records[1:] {
+		get := func(key string) string {
+			if i, ok := header[key]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+
+		var parseErr error
+		atoi := func(key string) int {
+			s := get(key)
+			if s == "" || parseErr != nil {
+				return 0
+			}
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				parseErr = fmt.Errorf("line %d: invalid %s %q", line+2, key, s)
+			}
+			return n
+		}
+
+		p := PacketInfo{
+			Version:        get("version"),
+			TotalLength:    int64(atoi("total_length")),
+			Flags:          get("flags"),
+			TTL:            atoi("ttl"),
+			Protocol:       get("protocol"),
+			HeaderChecksum: atoi("header_checksum"),
+			SourceIP:       get("source_ip"),
+			DestinationIP:  get("destination_ip"),
+			Malicious:      atoi("malicious"),
+			Suspicious:     atoi("suspicious"),
+			Harmless:       atoi("harmless"),
+			Undetected:     atoi("undetected"),
+			ScanDate:       get("scan_date"),
+			SourceSensor:   get("source_sensor"),
+		}
+		if s := get("checked_at"); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid checked_at %q", line+2, s)
+			}
+			p.CheckedAt = t
+		}
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		packets = append(packets, p)
+	}
+
+	return packets, nil
+}
@@ -1,30 +1,49 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"embed"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"html/template"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"golang.org/x/sync/semaphore"
 )
 
 //go:embed templates
 var templateFS embed.FS
 
-var db *sql.DB
+// dbConn holds the connection pool, which the DB watchdog may replace.
+var dbConn atomic.Pointer[sql.DB]
+
+// db returns the current connection pool. Callers fetch it per query
+// rather than keeping it, so a replaced pool is picked up.
+func db() *sql.DB {
+	return dbConn.Load()
+}
+
+// refreshInterval is how often the dashboard polls /api/packets.
+var refreshInterval time.Duration
 
 type PacketInfo struct {
 	ID             int       `json:"id"`
 	Version        string    `json:"version"`
-	TotalLength    int       `json:"total_length"`
+	TotalLength    int64     `json:"total_length"`
 	Flags          string    `json:"flags"`
 	TTL            int       `json:"ttl"`
 	Protocol       string    `json:"protocol"`
@@ -37,31 +56,314 @@ type PacketInfo struct {
 	Undetected     int       `json:"undetected"`
 	ScanDate       string    `json:"scan_date"`
 	CheckedAt      time.Time `json:"checked_at"`
+	SourceSensor   string    `json:"source_sensor"`
+	TCPFlags       *TCPFlags `json:"tcp_flags,omitempty"`
+
+	// Computed holds the COMPUTED_FIELDS values, null where undefined.
+	Computed map[string]*float64 `json:"computed,omitempty"`
 }
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
+	tail := flag.Bool("tail", false, "print new packets to stdout instead of serving HTTP")
+	tailProtocol := flag.String("protocol", "", "only tail packets with this protocol")
+	flag.Parse()
+
+	loadEnvFiles()
+
+	dbConn.Store(connectDB())
+	defer func() { db().Close() }()
+
+	if err := migrate(context.Background()); err != nil {
+		log.Fatal("Error migrating database: ", err)
+	}
+
+	queryTimeout = envDuration("QUERY_TIMEOUT", 10*time.Second)
+	queryTimeoutMax = max(envDuration("QUERY_TIMEOUT_MAX", 60*time.Second), queryTimeout)
+
+	queryCapacity = int64(envInt("QUERY_CONCURRENCY", 8))
+	querySem = semaphore.NewWeighted(queryCapacity)
+	queryQueueTimeout = envDuration("QUERY_QUEUE_TIMEOUT", 2*time.Second)
+
+	var err error
+	if queryComment, err = parseQueryComment(os.Getenv("QUERY_COMMENT")); err != nil {
+		log.Fatal(err)
+	}
+	if querySettings, err = parseQuerySettings(os.Getenv("QUERY_SET_LOCAL")); err != nil {
+		log.Fatal(err)
+	}
+
+	if envBool("AUTO_INDEX") {
+		if err := createIndexes(context.Background()); err != nil {
+			log.Fatal("Error creating indexes: ", err)
+		}
+	}
+
+	if path := os.Getenv("LOAD_SAMPLE"); path != "" {
+		n, err := loadSample(context.Background(), path)
+		if err != nil {
+			log.Fatal("Error loading sample data: ", err)
+		}
+		log.Printf("Loaded %d packets from %s", n, path)
+	}
+
+	if *tail {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := tailPackets(ctx, os.Stdout, *tailProtocol, envDuration("REFRESH_INTERVAL", time.Second)); err != nil {
+			log.Fatal("Tail error: ", err)
+		}
+		return
+	}
+
+	if envBool("WARMUP") {
+		if err := warmup(); err != nil {
+			log.Fatal("Warmup failed: ", err)
+		}
+	}
+
+	refreshInterval = envDuration("REFRESH_INTERVAL", time.Second)
+	maxQueryWindow = envDuration("MAX_QUERY_WINDOW", 0)
+	maxStringFieldLength = envInt("MAX_STRING_FIELD_LENGTH", maxStringFieldLength)
+	if computedFields, err = parseComputedFields(os.Getenv("COMPUTED_FIELDS")); err != nil {
+		log.Fatal("Invalid COMPUTED_FIELDS: ", err)
+	}
+	dashboardPageSize = min(envInt("DASHBOARD_PAGE_SIZE", dashboardPageSize), maxPacketLimit)
+	trustedProxies = parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	jsonCamelCase = os.Getenv("JSON_CASE") == "camel"
+	jsonOmitEmpty = envBool("JSON_OMIT_EMPTY")
+	emptyDBHint = envBool("EMPTY_DB_HINT")
+	errorTemplatePath = os.Getenv("ERROR_TEMPLATE")
+	adminToken = os.Getenv("ADMIN_TOKEN")
+	ingestToken = os.Getenv("INGEST_TOKEN")
+	sensorTokens = parseSensorTokens(os.Getenv("INGEST_SENSOR_TOKENS"))
+	maxStreamsPerIP = envInt("STREAM_MAX_PER_IP", maxStreamsPerIP)
+	maxStreamsTotal = envInt("STREAM_MAX_TOTAL", 0)
+	pollHintEnabled = envBool("POLL_HINT")
+	pollHintMin = envDuration("POLL_HINT_MIN", pollHintMin)
+	pollHintMax = max(envDuration("POLL_HINT_MAX", pollHintMax), pollHintMin)
+	pollHintTarget = envInt("POLL_HINT_TARGET", pollHintTarget)
+	alertWebhookURL = os.Getenv("ALERT_WEBHOOK_URL")
+	alertRetryMax = envInt("ALERT_RETRY_MAX", alertRetryMax)
+	alertRetryBackoff = envDuration("ALERT_RETRY_BACKOFF", alertRetryBackoff)
+	if quietHours, err = parseQuietHours(os.Getenv("QUIET_HOURS")); err != nil {
+		log.Fatal(err)
+	}
+	if tz := os.Getenv("QUIET_HOURS_TZ"); tz != "" {
+		if quietHoursLocation, err = time.LoadLocation(tz); err != nil {
+			log.Fatal("Invalid QUIET_HOURS_TZ: ", err)
+		}
+	}
+	alertCriticalMalicious = envInt("ALERT_CRITICAL_MALICIOUS", alertCriticalMalicious)
+
+	if url := os.Getenv("ADMIN_SQL_DB_URL"); url != "" {
+		if adminSQLDB, err = sql.Open("postgres", url); err != nil {
+			log.Fatal("Error opening admin SQL database: ", err)
+		}
+		defer adminSQLDB.Close()
+	}
+	adminSQLTimeout = envDuration("ADMIN_SQL_TIMEOUT", adminSQLTimeout)
+	adminSQLMaxRows = envInt("ADMIN_SQL_MAX_ROWS", adminSQLMaxRows)
+
+	if reputation, err = configureThreatProviders(os.Getenv("THREAT_PROVIDERS")); err != nil {
+		log.Fatal("Invalid THREAT_PROVIDERS: ", err)
+	}
+	// The VirusTotal public API allows 4 lookups per minute.
+	rescanLimiter = &intervalLimiter{every: envDuration("RESCAN_MIN_INTERVAL", 15*time.Second)}
+
+	connRateMaxDestinations = envInt("CONN_RATE_MAX_DESTINATIONS", 100)
+	connRateMaxPackets = envInt("CONN_RATE_MAX_PACKETS", 1000)
+
+	statsMaxLimit = envInt("STATS_MAX_LIMIT", statsMaxLimit)
+	statsDefaultLimit = min(envInt("STATS_DEFAULT_LIMIT", statsDefaultLimit), statsMaxLimit)
+
+	configureCacheMaxAges(os.Getenv("CACHE_MAX_AGE"), os.Getenv("CACHE_MAX_AGE_ROUTES"))
+
+	mux := http.NewServeMux()
+
+	// MODE=api serves only the JSON API; the dashboard and its templates
+	// are never loaded.
+	mode := os.Getenv("MODE")
+	if mode != "api" {
+		mux.HandleFunc("/", limitQueries(readQueryWeight, handleDashboard))
+		mux.HandleFunc("/fragments/packets-table", limitQueries(readQueryWeight, handlePacketRowsFragment))
+	}
+	api := []apiRoute{
+		{"/packets", limitQueries(readQueryWeight, pollHint(handlePacketsAPI))},
+		{"/validate-filter", handleValidateFilter},
+		{"/query", limitQueries(readQueryWeight, handleQueryAPI)},
+		{"/packets/malicious", limitQueries(readQueryWeight, handleMaliciousAPI)},
+		{"/packets/acknowledge", requireAdmin(handleAcknowledgeAPI)},
+		{"/packets/delta", limitQueries(readQueryWeight, pollHint(handlePacketsDeltaAPI))},
+		{"/packets/{id}/context", limitQueries(readQueryWeight, handlePacketContextAPI)},
+		{"/packets/by-ip", requireAdmin(handleDeleteByIP)},
+		{"/packets.parquet", limitQueries(readQueryWeight, handlePacketsParquet)},
+		{"/packets/wireshark.csv", limitQueries(readQueryWeight, handleWiresharkCSV)},
+		{"/replay", limitStreams(handleReplayAPI)},
+		{"/stream/count", limitStreams(handleCountStreamAPI)},
+		{"/ingest", handleIngestAPI},
+		{"/rescan", requireAdmin(handleRescanAPI)},
+		{"/schema", handleSchemaAPI},
+		{"/compare", limitQueries(aggregateQueryWeight, handleCompareAPI)},
+		{"/sparkline", limitQueries(aggregateQueryWeight, handleSparklineAPI)},
+		{"/new-ips", limitQueries(aggregateQueryWeight, handleNewIPsAPI)},
+		{"/trends", limitQueries(readQueryWeight, handleTrendsAPI)},
+		{"/export/timeseries.csv", limitQueries(aggregateQueryWeight, handleTimeseriesCSV)},
+		{"/trends/malicious-ratio", limitQueries(aggregateQueryWeight, handleMaliciousRatioAPI)},
+		{"/protocols", limitQueries(aggregateQueryWeight, handleProtocolsAPI)},
+		{"/protocol/{name}", limitQueries(aggregateQueryWeight, handleProtocolAPI)},
+		{"/rate", limitQueries(aggregateQueryWeight, handleRateAPI)},
+		{"/stats", limitQueries(aggregateQueryWeight, handleStatsAPI)},
+		{"/stats/checksums", limitQueries(aggregateQueryWeight, handleChecksumStatsAPI)},
+		{"/stats/connection-rate", limitQueries(aggregateQueryWeight, handleConnectionRateAPI)},
+		{"/top-targets", limitQueries(aggregateQueryWeight, handleTopTargetsAPI)},
+		{"/stats/ttl", limitQueries(aggregateQueryWeight, handleTTLStatsAPI)},
+		{"/stats/flags", limitQueries(aggregateQueryWeight, handleFlagsStatsAPI)},
+		{"/stats/subnets", limitQueries(aggregateQueryWeight, handleSubnetStatsAPI)},
+		{"/stats/size-threat", limitQueries(aggregateQueryWeight, handleSizeThreatAPI)},
+		{"/freshness", limitQueries(readQueryWeight, handleFreshnessAPI)},
+	}
+	if envBool("SNAPSHOT_ENABLED") {
+		api = append(api, apiRoute{"/snapshot.png", limitQueries(aggregateQueryWeight, handleSnapshot)})
+	}
+	registerAPI(mux, "/api/v1", api)
+	// Unversioned paths stay as aliases of v1 for existing clients.
+	registerAPI(mux, "/api", api)
+
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/admin/latency", requireAdmin(handleAdminLatency))
+	mux.HandleFunc("/admin/cache", requireAdmin(handleAdminCache))
+	mux.HandleFunc("/admin/cache/flush", requireAdmin(handleAdminCacheFlush))
+	mux.HandleFunc("/debug/selftest", requireAdmin(handleSelfTest))
+	mux.HandleFunc("/admin/sql", handleAdminSQL)
+
+	stmtCacheSize = envInt("STMT_CACHE_SIZE", 0)
+	countStreamInterval = envDuration("STREAM_COUNT_INTERVAL", countStreamInterval)
+	if exportRedactFields, err = parseRedactFields(os.Getenv("EXPORT_REDACT_FIELDS")); err != nil {
+		log.Fatal(err)
+	}
+	if scanDateLayout, err = parseScanDateFormat(os.Getenv("SCAN_DATE_FORMAT")); err != nil {
+		log.Fatal(err)
 	}
 
-	db = connectDB()
-	defer db.Close()
+	maintenanceMode = envBool("MAINTENANCE")
+	maintenanceFile = os.Getenv("MAINTENANCE_FILE")
+	maintenanceRetryAfter = envDuration("MAINTENANCE_RETRY_AFTER", maintenanceRetryAfter)
 
-	http.HandleFunc("/", handleDashboard)
-	http.HandleFunc("/api/packets", handlePacketsAPI)
+	staleTTL = envDuration("STALE_TTL", 0)
+	staleThreshold = envDuration("STALE_THRESHOLD", 2*time.Second)
+
+	var handler http.Handler = mux
+	handler = cacheControl(handler)
+	handler = staleFallback(handler)
+	handler = trackLatency(handler)
+	handler = maintenance(handler)
+	handler = limitBody(handler, int64(envInt("MAX_BODY_BYTES", defaultMaxBodyBytes)))
+	handler = trackInFlight(handler)
+	handler = accessLog(handler, os.Getenv("ACCESS_LOG_FORMAT"))
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	fmt.Printf("Server starting on http://localhost:%s\n", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	// BIND_ADDR restricts listening to one interface; empty means all.
+	bindAddr := os.Getenv("BIND_ADDR")
+	server := &http.Server{Addr: net.JoinHostPort(bindAddr, port), Handler: handler}
+
+	// TLS_CERT_FILE and TLS_KEY_FILE switch the listener to HTTPS.
+	certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	if (certFile == "") != (keyFile == "") {
+		log.Fatal("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if certFile != "" {
+		if server.TLSConfig, err = serverTLSConfig(os.Getenv("TLS_MIN_VERSION"), os.Getenv("TLS_CIPHER_SUITES")); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// RAW_RETENTION downsamples raw packets into rollups; unset keeps them.
+	rawRetention := envDuration("RAW_RETENTION", 0)
+	if envBool("ROLLUP_ENABLED") {
+		go runRollups(ctx,
+			envDuration("ROLLUP_INTERVAL", 15*time.Minute),
+			envDuration("ROLLUP_RETENTION", 90*24*time.Hour),
+			rawRetention)
+	} else if rawRetention > 0 {
+		log.Printf("RAW_RETENTION ignored: raw packets are only purged with ROLLUP_ENABLED")
+	}
+	if envBool("DB_WATCHDOG") {
+		go runDBWatchdog(ctx,
+			envDuration("DB_WATCHDOG_INTERVAL", 10*time.Second),
+			envInt("DB_WATCHDOG_FAILURES", 5))
+	}
+	if alertWebhookURL != "" {
+		initAlertDeliveries(ctx)
+	}
+	if len(quietHours) > 0 {
+		go runQuietHoursSummary(ctx)
+	}
+	if envBool("PROTOCOL_MIX_ALERT") {
+		go runProtocolMixCheck(ctx, protocolMixConfig{
+			Window:     envDuration("PROTOCOL_MIX_WINDOW", 5*time.Minute),
+			Baseline:   envDuration("PROTOCOL_MIX_BASELINE", 24*time.Hour),
+			Threshold:  float64(envInt("PROTOCOL_MIX_THRESHOLD", 30)),
+			MinPackets: envInt("PROTOCOL_MIX_MIN_PACKETS", 100),
+		})
+	}
+	if maxGap := envDuration("MAX_INGEST_GAP", 0); maxGap > 0 {
+		go runIngestGapCheck(ctx, maxGap, envDuration("INGEST_GAP_INTERVAL", time.Minute))
+	}
+	if pushURL := os.Getenv("PUSHGATEWAY_URL"); pushURL != "" {
+		job := os.Getenv("PUSHGATEWAY_JOB")
+		if job == "" {
+			job = "network_monitor_dashboard"
+		}
+		go runPushgateway(ctx, pushURL, job, envDuration("PUSHGATEWAY_INTERVAL", 30*time.Second))
+	}
+
+	go func() {
+		host := bindAddr
+		if host == "" {
+			host = "localhost"
+		}
+		var err error
+		if certFile != "" {
+			fmt.Printf("Server starting on https://%s\n", net.JoinHostPort(host, port))
+			err = server.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			fmt.Printf("Server starting on http://%s\n", net.JoinHostPort(host, port))
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+
+	grace := envDuration("SHUTDOWN_GRACE_PERIOD", 10*time.Second)
+	log.Printf("Shutting down, %d request(s) in flight, grace period %s", inFlight.Load(), grace)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Shutdown incomplete, %d request(s) still in flight: %v", inFlight.Load(), err)
+	}
+}
+
+// databaseURL is DB_URL, or a DSN built from the DB_* variables.
+func databaseURL() string {
+	if url := os.Getenv("DB_URL"); url != "" {
+		return url
+	}
+	return dsnFromEnv()
 }
 
 func connectDB() *sql.DB {
-	dbURL := os.Getenv("DB_URL")
-	database, err := sql.Open("postgres", dbURL)
+	database, err := sql.Open("postgres", databaseURL())
 	if err != nil {
 		log.Fatal("Error opening database: ", err)
 	}
@@ -73,18 +375,50 @@ func connectDB() *sql.DB {
 	return database
 }
 
-func getPackets(afterID int, limit int) ([]PacketInfo, error) {
-	query := `
+// getPackets lists packets matching f newest first. With after_id alone it
+// returns the rows immediately above after_id rather than the newest ones,
+// so a client polling with the highest id it has seen never skips rows
+// when more than a page has arrived since; it just needs more polls. Rows
+// committed while an older transaction is still open are held back until
+// it ends, so one committing late can't leave a lower id behind the poll.
+func getPackets(ctx context.Context, f packetFilter) ([]PacketInfo, error) {
+	if f.AfterID > 0 && f.BeforeID <= 0 {
+		f.Settled = true
+		packets, err := queryPackets(ctx, f, "id ASC")
+		slices.Reverse(packets)
+		return packets, err
+	}
+	return queryPackets(ctx, f, "id DESC")
+}
+
+// queryPackets selects packets matching f in the given ORDER BY order.
+// orderBy must be a constant, never user input.
+func queryPackets(ctx context.Context, f packetFilter, orderBy string) ([]PacketInfo, error) {
+	where, args := f.listingWhere()
+	return selectPackets(ctx, where, args, orderBy, f.Limit)
+}
+
+// selectPackets runs the packet listing query with a prebuilt WHERE clause
+// whose placeholders are numbered from $1 to match args.
+func selectPackets(ctx context.Context, where string, args []any, orderBy string, limit int) ([]PacketInfo, error) {
+	args = append(args, limit)
+	query := fmt.Sprintf(`
 		SELECT id, version, total_length, flags, ttl, protocol, header_checksum,
 		       source_ip, destination_ip, malicious, suspicious, harmless,
-		       undetected, scan_date, checked_at
+		       undetected, scan_date, checked_at, source_sensor
 		FROM packet_info
-		WHERE id > $1
-		ORDER BY id DESC
-		LIMIT $2
-	`
+		%s
+		ORDER BY %s
+		LIMIT $%d
+	`, where, orderBy, len(args))
+
+	q, done, err := beginTunedQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
 
-	rows, err := db.Query(query, afterID, limit)
+	rows, err := queryCached(ctx, q, tagQuery(query), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -93,24 +427,30 @@ func getPackets(afterID int, limit int) ([]PacketInfo, error) {
 	var packets []PacketInfo
 	for rows.Next() {
 		var p PacketInfo
-		var scanDate sql.NullTime
-		var flags sql.NullString
+		var scanDate any
+		var flags, sensor sql.NullString
 
 		err := rows.Scan(
 			&p.ID, &p.Version, &p.TotalLength, &flags, &p.TTL, &p.Protocol,
 			&p.HeaderChecksum, &p.SourceIP, &p.DestinationIP, &p.Malicious,
 			&p.Suspicious, &p.Harmless, &p.Undetected, &scanDate, &p.CheckedAt,
+			&sensor,
 		)
 		if err != nil {
 			return nil, err
 		}
 
 		if flags.Valid {
-			p.Flags = flags.String
+			p.Flags = truncateField(p.ID, "flags", flags.String)
 		}
-		if scanDate.Valid {
-			p.ScanDate = scanDate.Time.Format("2006-01-02")
+		p.Version = truncateField(p.ID, "version", p.Version)
+		p.SourceSensor = sensor.String
+		if isTCP(p.Protocol) {
+			p.TCPFlags = parseFlags(p.Flags)
 		}
+		p.ScanDate = formatScanDate(p.ID, scanDate)
+		p.TotalLength = clampTotalLength(p.ID, p.TotalLength)
+		p.Computed = computeFields(p)
 
 		packets = append(packets, p)
 	}
@@ -118,39 +458,216 @@ func getPackets(afterID int, limit int) ([]PacketInfo, error) {
 	return packets, nil
 }
 
+type dashboardData struct {
+	Packets         []PacketInfo
+	RefreshInterval int64 // milliseconds
+	PageSize        int
+
+	// Keyset links to the neighbouring pages; zero when there is none.
+	// Live is true on the newest page, the only one that polls for rows.
+	OlderBeforeID int
+	NewerAfterID  int
+	Live          bool
+
+	// Empty is true when there are no packets at all yet.
+	Empty bool
+}
+
+// dashboardPageSize is the number of rows per dashboard page
+// (DASHBOARD_PAGE_SIZE).
+var dashboardPageSize = 100
+
+// getDashboardPage loads one page of the dashboard: the newest rows, rows
+// below before_id, or rows just above after_id (paging back towards newer).
+func getDashboardPage(ctx context.Context, r *http.Request) (dashboardData, error) {
+	data := dashboardData{PageSize: dashboardPageSize, RefreshInterval: refreshInterval.Milliseconds()}
+	q := r.URL.Query()
+	if s := q.Get("page_size"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			data.PageSize = min(n, maxPacketLimit)
+		}
+	}
+	f := packetFilter{Limit: data.PageSize}
+	f.BeforeID, _ = strconv.Atoi(q.Get("before_id"))
+	f.AfterID, _ = strconv.Atoi(q.Get("after_id"))
+
+	if f.BeforeID > 0 {
+		f.AfterID = 0
+	}
+	var err error
+	data.Packets, err = getPackets(ctx, f)
+	if err != nil {
+		return data, err
+	}
+
+	if n := len(data.Packets); n > 0 {
+		newest, oldest := data.Packets[0].ID, data.Packets[n-1].ID
+		if n == data.PageSize || f.AfterID > 0 {
+			data.OlderBeforeID = oldest
+		}
+		if f.BeforeID > 0 || (f.AfterID > 0 && n == data.PageSize) {
+			data.NewerAfterID = newest
+		}
+	}
+	data.Live = data.NewerAfterID == 0
+	// The unfiltered newest page only comes back empty on an empty table.
+	data.Empty = len(data.Packets) == 0 && f.BeforeID <= 0 && f.AfterID <= 0
+	return data, nil
+}
+
 func handleDashboard(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := template.ParseFS(templateFS, "templates/dashboard.html")
+	ctx, cancel, err := queryContext(r)
 	if err != nil {
-		http.Error(w, "Error loading template", http.StatusInternalServerError)
-		log.Printf("Template error: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	defer cancel()
 
-	packets, err := getPackets(0, 1000)
+	data, err := getDashboardPage(ctx, r)
 	if err != nil {
-		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		renderErrorPage(w, http.StatusServiceUnavailable, "Error fetching data",
+			"The packet database is currently unavailable. The dashboard will work again once it is reachable.")
 		log.Printf("Database error: %v", err)
 		return
 	}
 
-	tmpl.Execute(w, packets)
+	// Unchanged data renders an identical page, so a revalidating browser
+	// gets a 304 and the template is not executed at all.
+	pages := []string{"templates/dashboard.html", "templates/packet_rows.html"}
+	etag, err := dataETag(data, pages...)
+	if err != nil {
+		log.Printf("Dashboard ETag error: %v", err)
+	}
+	if etag != "" && etagMatches(r, etag) {
+		setETag(w, etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	tmpl, err := template.ParseFS(templateFS, pages...)
+	if err != nil {
+		renderErrorPage(w, http.StatusInternalServerError, "Error loading template",
+			"The dashboard could not be loaded. Please try again or contact an administrator.")
+		log.Printf("Template error: %v", err)
+		return
+	}
+
+	// Render into a buffer so a failed execution can still produce a clean
+	// 500 instead of a half-written page.
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		renderErrorPage(w, http.StatusInternalServerError, "Error rendering page",
+			"The dashboard could not be rendered. Please try again or contact an administrator.")
+		log.Printf("Template execute error: %v", err)
+		return
+	}
+
+	if etag != "" {
+		setETag(w, etag)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
 }
 
 func handlePacketsAPI(w http.ResponseWriter, r *http.Request) {
-	afterID := 0
-	if idStr := r.URL.Query().Get("after_id"); idStr != "" {
-		if id, err := strconv.Atoi(idStr); err == nil {
-			afterID = id
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	filter, err := parsePacketFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if filter.Cursor != nil {
+		filter.Settled = true
+		packets, err := queryPackets(ctx, filter, "checked_at, id")
+		if err != nil {
+			http.Error(w, "Error fetching data", http.StatusInternalServerError)
+			log.Printf("Database error: %v", err)
+			return
 		}
+		setNextCursor(w, filter, packets)
+		writeJSON(w, r, packets)
+		return
 	}
 
-	packets, err := getPackets(afterID, 1000)
+	packets, err := getPackets(ctx, filter)
 	if err != nil {
 		http.Error(w, "Error fetching data", http.StatusInternalServerError)
 		log.Printf("Database error: %v", err)
 		return
 	}
 
+	if filter.Collapse == "" {
+		setPaginationLinks(w, r, filter, packets)
+	}
+
+	if len(packets) == 0 && emptyDBHint {
+		empty, err := packetTableEmpty(ctx)
+		if err != nil {
+			http.Error(w, "Error fetching data", http.StatusInternalServerError)
+			log.Printf("Database error: %v", err)
+			return
+		}
+		if empty {
+			writeJSON(w, r, emptyPackets{Data: []PacketInfo{}, Empty: true})
+			return
+		}
+	}
+
+	// ?hints=true wraps the list so it can carry notes on filter values
+	// that match nothing at all.
+	if hints, _ := strconv.ParseBool(r.URL.Query().Get("hints")); hints {
+		h, err := filterHints(ctx, filter)
+		if err != nil {
+			http.Error(w, "Error fetching data", http.StatusInternalServerError)
+			log.Printf("Database error: %v", err)
+			return
+		}
+		if packets == nil {
+			packets = []PacketInfo{}
+		}
+		writeJSON(w, r, packetsWithHints{Packets: packets, Hints: h})
+		return
+	}
+
+	writeJSON(w, r, packets)
+}
+
+// writeJSON encodes v as the response body. Output is compact unless the
+// client asks for ?pretty=true or Accept: application/json+pretty.
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	writeJSONStatus(w, r, http.StatusOK, v)
+}
+
+func writeJSONStatus(w http.ResponseWriter, r *http.Request, status int, v any) {
+	if jsonCamelCase {
+		camel, err := toCamelCaseJSON(v)
+		if err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			log.Printf("JSON encode error: %v", err)
+			return
+		}
+		v = camel
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(packets)
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	if wantsPrettyJSON(r) {
+		enc.SetIndent("", "  ")
+	}
+	enc.Encode(v)
+}
+
+func wantsPrettyJSON(r *http.Request) bool {
+	if pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty")); pretty {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json+pretty")
 }
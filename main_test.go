@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// TestAfterIDPollingBurst polls with after_id while bursts larger than the
+// page arrive, and checks every page holds the rows just above after_id,
+// newest first, so the client's "highest id seen" never jumps a row.
+func TestAfterIDPollingBurst(t *testing.T) {
+	openTestDB(t)
+	const limit = 10
+
+	inserted := insertTestPackets(t, 5)
+	lastID := inserted[len(inserted)-1]
+	var seen []int
+	for burst := range 3 {
+		// Each burst is more than two pages.
+		inserted = append(inserted, insertTestPackets(t, 2*limit+3+burst)...)
+
+		for {
+			page, err := getPackets(context.Background(), packetFilter{AfterID: lastID, Limit: limit})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page) == 0 {
+				break
+			}
+			ids := make([]int, len(page))
+			for i, p := range page {
+				ids[i] = p.ID
+			}
+			if !slices.IsSortedFunc(ids, func(a, b int) int { return b - a }) {
+				t.Fatalf("page after %d not newest first: %v", lastID, ids)
+			}
+			slices.Reverse(ids)
+			want := inserted[slices.Index(inserted, lastID)+1:]
+			want = want[:min(limit, len(want))]
+			if !slices.Equal(ids, want) {
+				t.Fatalf("page after %d = %v, want the rows just above it %v", lastID, ids, want)
+			}
+			seen = append(seen, ids...)
+			lastID = page[0].ID
+		}
+	}
+
+	if want := inserted[5:]; !slices.Equal(seen, want) {
+		t.Errorf("polling saw %d rows, want all %d inserted after the first id: %v", len(seen), len(want), seen)
+	}
+}
+
+// TestAfterIDPollingLateCommit has a transaction take a lower id and commit
+// after another with a higher one. Polling must hold the higher row back
+// until the lower one is visible, then return both.
+func TestAfterIDPollingLateCommit(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	lastID := insertTestPackets(t, 1)[0]
+
+	late, err := db().BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer late.Rollback()
+	lower := insertTestPacketsWith(t, late, 1)[0]
+	higher := insertTestPackets(t, 1)[0]
+	if higher < lower {
+		t.Fatalf("ids out of order: %d then %d", lower, higher)
+	}
+
+	poll := func() []int {
+		t.Helper()
+		page, err := getPackets(ctx, packetFilter{AfterID: lastID, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]int, len(page))
+		for i, p := range page {
+			ids[i] = p.ID
+		}
+		return ids
+	}
+
+	if ids := poll(); len(ids) != 0 {
+		t.Fatalf("poll while %d is uncommitted = %v, want nothing", lower, ids)
+	}
+	if err := late.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if ids, want := poll(), []int{higher, lower}; !slices.Equal(ids, want) {
+		t.Fatalf("poll after commit = %v, want %v", ids, want)
+	}
+}
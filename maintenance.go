@@ -0,0 +1,42 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Maintenance mode answers every route except /healthz with a 503 page.
+// It is on while MAINTENANCE is true or while maintenanceFile exists
+// (MAINTENANCE_FILE), so it can be toggled without a restart.
+var (
+	maintenanceMode       bool
+	maintenanceFile       string
+	maintenanceRetryAfter = 5 * time.Minute
+)
+
+func inMaintenance() bool {
+	if maintenanceMode {
+		return true
+	}
+	if maintenanceFile == "" {
+		return false
+	}
+	_, err := os.Stat(maintenanceFile)
+	return err == nil
+}
+
+func maintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" || !inMaintenance() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(maintenanceRetryAfter.Seconds()))))
+		w.Header().Set("Cache-Control", "no-store")
+		renderErrorPage(w, http.StatusServiceUnavailable, "Down for maintenance",
+			"The dashboard is offline for planned maintenance and will be back shortly.")
+	})
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log"
+)
+
+// columnMigrations add optional columns to packet_info that newer features
+// use. Each runs only when the column is missing, so a database user
+// without ALTER rights works once a DBA has applied them by hand.
+var columnMigrations = []struct {
+	column, ddl string
+}{
+	{"source_sensor", `ALTER TABLE packet_info ADD COLUMN IF NOT EXISTS source_sensor TEXT`},
+	{"acknowledged", `ALTER TABLE packet_info ADD COLUMN IF NOT EXISTS acknowledged BOOLEAN NOT NULL DEFAULT false`},
+}
+
+func migrate(ctx context.Context) error {
+	for _, m := range columnMigrations {
+		var exists bool
+		err := db().QueryRowContext(ctx, tagQuery(`
+			SELECT EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_schema = current_schema() AND table_name = 'packet_info' AND column_name = $1
+			)`), m.column).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := db().ExecContext(ctx, tagQuery(m.ddl)); err != nil {
+			return err
+		}
+		log.Printf("Migrated packet_info: added column %s", m.column)
+	}
+	return nil
+}
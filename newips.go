@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+type newIP struct {
+	SourceIP  string    `json:"source_ip"`
+	FirstSeen time.Time `json:"first_seen"`
+	Packets   int       `json:"packets"`
+}
+
+// getNewSourceIPs returns source IPs whose earliest packet is at or after since.
+func getNewSourceIPs(ctx context.Context, since time.Time, limit int) ([]newIP, error) {
+	query := `
+		SELECT source_ip, MIN(checked_at) AS first_seen, COUNT(*)
+		FROM packet_info
+		GROUP BY source_ip
+		HAVING MIN(checked_at) >= $1
+		ORDER BY first_seen DESC, source_ip
+		LIMIT $2
+	`
+
+	rows, err := db().QueryContext(ctx, tagQuery(query), since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []newIP{}
+	for rows.Next() {
+		var ip newIP
+		if err := rows.Scan(&ip.SourceIP, &ip.FirstSeen, &ip.Packets); err != nil {
+			return nil, err
+		}
+		results = append(results, ip)
+	}
+
+	return results, rows.Err()
+}
+
+func handleNewIPsAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	since, err := windowParam(r, "since", 24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := statsLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := getNewSourceIPs(ctx, time.Now().Add(-since), limit)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	writeJSON(w, r, results)
+}
@@ -0,0 +1,26 @@
+package main
+
+import "encoding/json"
+
+// jsonOmitEmpty drops optional packet fields that have no value instead of
+// sending them as empty strings (JSON_OMIT_EMPTY).
+var jsonOmitEmpty bool
+
+// MarshalJSON applies jsonOmitEmpty to the genuinely optional fields: flags
+// (NULL for non-TCP traffic), scan_date (NULL until the IP is scanned) and
+// source_sensor (NULL for packets not posted by a sensor).
+// A blanket omitempty can't be used because it would also drop meaningful
+// zeros such as ttl 0 or a clean malicious count of 0.
+func (p PacketInfo) MarshalJSON() ([]byte, error) {
+	type plain PacketInfo
+	if !jsonOmitEmpty {
+		return json.Marshal(plain(p))
+	}
+	// The outer fields shadow the embedded ones of the same JSON name.
+	return json.Marshal(struct {
+		plain
+		Flags        string `json:"flags,omitempty"`
+		ScanDate     string `json:"scan_date,omitempty"`
+		SourceSensor string `json:"source_sensor,omitempty"`
+	}{plain(p), p.Flags, p.ScanDate, p.SourceSensor})
+}
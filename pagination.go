@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// packetCursor is a position in (checked_at, id) order. Unlike after_id it
+// still finds rows that were inserted with an id below ones already seen
+// (backfills, sequences with gaps), as long as their checked_at is later.
+type packetCursor struct {
+	CheckedAt time.Time
+	ID        int
+}
+
+// encodeCursor renders c as an opaque URL-safe token.
+func encodeCursor(c packetCursor) string {
+	raw := c.CheckedAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a token from encodeCursor. The empty token starts at
+// the beginning.
+func decodeCursor(s string) (packetCursor, error) {
+	if s == "" {
+		return packetCursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return packetCursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	ts, idStr, ok := strings.Cut(string(raw), "|")
+	t, tErr := time.Parse(time.RFC3339Nano, ts)
+	id, idErr := strconv.Atoi(idStr)
+	if !ok || tErr != nil || idErr != nil {
+		return packetCursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	return packetCursor{CheckedAt: t, ID: id}, nil
+}
+
+// nextCursor is where a polling client continues after packets. An empty
+// page repeats the cursor it was asked for.
+func nextCursor(f packetFilter, packets []PacketInfo) packetCursor {
+	if len(packets) == 0 {
+		return *f.Cursor
+	}
+	last := packets[len(packets)-1]
+	return packetCursor{CheckedAt: last.CheckedAt, ID: last.ID}
+}
+
+// setNextCursor tells a polling client where to continue.
+func setNextCursor(w http.ResponseWriter, f packetFilter, packets []PacketInfo) {
+	w.Header().Set("X-Next-Cursor", encodeCursor(nextCursor(f, packets)))
+}
+
+// setPaginationLinks emits an RFC 5988 Link header for a keyset-paginated
+// packet listing. Pages run newest to oldest: "next" continues below the
+// last id returned, "prev" goes back above the first one.
+func setPaginationLinks(w http.ResponseWriter, r *http.Request, f packetFilter, packets []PacketInfo) {
+	link := func(rel string, set map[string]int) string {
+		q := r.URL.Query()
+		q.Del("after_id")
+		q.Del("before_id")
+		q.Set("limit", strconv.Itoa(f.Limit))
+		for k, v := range set {
+			q.Set(k, strconv.Itoa(v))
+		}
+		u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+	}
+
+	links := []string{link("first", nil)}
+	if len(packets) > 0 && len(packets) == f.Limit {
+		links = append(links, link("next", map[string]int{"before_id": packets[len(packets)-1].ID}))
+	}
+	// An after_id page that came back full has more newer rows above it.
+	if len(packets) > 0 && (f.BeforeID > 0 || (f.AfterID > 0 && len(packets) == f.Limit)) {
+		links = append(links, link("prev", map[string]int{"after_id": packets[0].ID}))
+	}
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// commonParams are accepted by every API route: ?pretty= by writeJSON and
+// ?allow_large= by checkQueryWindow.
+var commonParams = []string{"pretty", "allow_large"}
+
+// packetFilterParams are the parameters parsePacketFilter reads.
+var packetFilterParams = []string{
+	"after_id", "before_id", "cursor", "from", "to", "header_checksum",
+	"protocol", "sensor", "ip_scope", "ip_scope_on", "exclude_undetected_only",
+	"verdict_age_max", "include_unscanned",
+	"collapse", "source_ip", "destination_ip", "limit",
+}
+
+// routeParams lists the query parameters each API route understands, keyed
+// by its path relative to the version prefix. Requests naming anything
+// else get a 400, so a typo like ?protcol=tcp doesn't silently match
+// everything. Routes missing here aren't checked.
+var routeParams = map[string][]string{
+	"/packets":                append([]string{"hints"}, packetFilterParams...),
+	"/validate-filter":        packetFilterParams,
+	"/query":                  nil,
+	"/packets/malicious":      append([]string{"include_acknowledged"}, packetFilterParams...),
+	"/packets/acknowledge":    nil,
+	"/packets/delta":          append([]string{"columns"}, packetFilterParams...),
+	"/packets/{id}/context":   {"window"},
+	"/packets/by-ip":          {"ip"},
+	"/packets.parquet":        packetFilterParams,
+	"/packets/wireshark.csv":  packetFilterParams,
+	"/replay":                 append([]string{"speed"}, packetFilterParams...),
+	"/stream/count":           nil,
+	"/ingest":                 nil,
+	"/rescan":                 {"ip"},
+	"/schema":                 nil,
+	"/compare":                {"window"},
+	"/sparkline":              {"protocol", "buckets", "window"},
+	"/new-ips":                {"since", "limit"},
+	"/trends":                 {"window", "protocol", "limit"},
+	"/export/timeseries.csv":  {"interval", "window", "limit"},
+	"/trends/malicious-ratio": {"interval", "window", "limit"},
+	"/protocols":              {"window", "limit"},
+	"/protocol/{name}":        nil,
+	"/rate":                   {"interval", "window", "smooth", "limit"},
+	"/stats":                  {"window", "limit"},
+	"/stats/checksums":        {"threshold", "limit"},
+	"/stats/connection-rate":  {"window", "limit"},
+	"/top-targets":            {"window", "limit"},
+	"/stats/ttl":              {"group"},
+	"/stats/flags":            {"protocol", "limit"},
+	"/stats/subnets":          {"mask", "mask6", "limit"},
+	"/stats/size-threat":      {"window"},
+	"/freshness":              nil,
+	"/snapshot.png":           {"window"},
+}
+
+// checkParams rejects requests with query parameters outside known (plus
+// commonParams), suggesting the closest known name for each.
+func checkParams(known []string, next http.HandlerFunc) http.HandlerFunc {
+	known = append(slices.Clip(known), commonParams...)
+	return func(w http.ResponseWriter, r *http.Request) {
+		var unknown []string
+		for name := range r.URL.Query() {
+			if !slices.Contains(known, name) {
+				unknown = append(unknown, name)
+			}
+		}
+		if len(unknown) == 0 {
+			next(w, r)
+			return
+		}
+
+		slices.Sort(unknown)
+		msgs := make([]string, len(unknown))
+		for i, name := range unknown {
+			msgs[i] = fmt.Sprintf("%q", name)
+			if s := suggestParam(name, known); s != "" {
+				msgs[i] += fmt.Sprintf(" (did you mean %q?)", s)
+			}
+		}
+		noun := "parameter"
+		if len(unknown) > 1 {
+			noun = "parameters"
+		}
+		http.Error(w, fmt.Sprintf("Unknown query %s %s; this endpoint accepts: %s",
+			noun, strings.Join(msgs, ", "), strings.Join(known, ", ")), http.StatusBadRequest)
+	}
+}
+
+// suggestParam returns the known name closest to name by edit distance, or
+// "" if none is close enough to be a plausible typo.
+func suggestParam(name string, known []string) string {
+	best, bestDist := "", max(2, len(name)/3)+1
+	for _, k := range known {
+		if d := editDistance(strings.ToLower(name), k); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetPacket is the Parquet schema of /api/packets.parquet. Nullable
+// database columns are optional so pandas and Spark read them as nulls.
+type parquetPacket struct {
+	ID             int64      `parquet:"id"`
+	Version        string     `parquet:"version"`
+	TotalLength    int32      `parquet:"total_length"`
+	Flags          *string    `parquet:"flags,optional"`
+	TTL            int32      `parquet:"ttl"`
+	Protocol       string     `parquet:"protocol"`
+	HeaderChecksum int32      `parquet:"header_checksum"`
+	SourceIP       string     `parquet:"source_ip"`
+	DestinationIP  string     `parquet:"destination_ip"`
+	Malicious      int32      `parquet:"malicious"`
+	Suspicious     int32      `parquet:"suspicious"`
+	Harmless       int32      `parquet:"harmless"`
+	Undetected     int32      `parquet:"undetected"`
+	ScanDate       *time.Time `parquet:"scan_date,optional,timestamp(microsecond)"`
+	CheckedAt      time.Time  `parquet:"checked_at,timestamp(microsecond)"`
+	SourceSensor   *string    `parquet:"source_sensor,optional"`
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// optionalScanDate parses the rendered scan_date back into a timestamp;
+// empty or unparseable values are exported as null.
+func optionalScanDate(s string) *time.Time {
+	if s == "" {
+		return nil
+	}
+	t, ok := parseScanDateString(s)
+	if !ok {
+		return nil
+	}
+	return &t
+}
+
+func toParquetPacket(p PacketInfo) parquetPacket {
+	return parquetPacket{
+		ID:             int64(p.ID),
+		Version:        p.Version,
+		TotalLength:    int32(p.TotalLength), // clamped to 0-65535 on read
+		Flags:          optionalString(p.Flags),
+		TTL:            int32(p.TTL),
+		Protocol:       p.Protocol,
+		HeaderChecksum: int32(p.HeaderChecksum),
+		SourceIP:       p.SourceIP,
+		DestinationIP:  p.DestinationIP,
+		Malicious:      int32(p.Malicious),
+		Suspicious:     int32(p.Suspicious),
+		Harmless:       int32(p.Harmless),
+		Undetected:     int32(p.Undetected),
+		ScanDate:       optionalScanDate(p.ScanDate),
+		CheckedAt:      p.CheckedAt,
+		SourceSensor:   optionalString(p.SourceSensor),
+	}
+}
+
+// parquetPageSize is how many packets the Parquet export reads per query;
+// each page becomes one row group.
+var parquetPageSize = 10000
+
+// handlePacketsParquet exports the /api/packets listing as a Parquet file
+// for loading straight into pandas or Spark. It takes the same filters and
+// streams every matching packet, newest first, paging by id; an explicit
+// ?limit= caps the total.
+func handlePacketsParquet(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	filter, err := parsePacketFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	remaining := -1
+	if r.URL.Query().Has("limit") {
+		remaining = filter.Limit
+	}
+	where, args := filter.listingWhere()
+
+	fetch := func(ctx context.Context, beforeID, limit int) ([]PacketInfo, error) {
+		if beforeID == 0 {
+			return selectPackets(ctx, where, args, "id DESC", limit)
+		}
+		// Outside the listing's WHERE, so collapse keeps one row per value.
+		pageArgs := append(slices.Clip(args), beforeID)
+		cond := fmt.Sprintf("id < $%d", len(pageArgs))
+		if where == "" {
+			return selectPackets(ctx, "WHERE "+cond, pageArgs, "id DESC", limit)
+		}
+		return selectPackets(ctx, where+" AND "+cond, pageArgs, "id DESC", limit)
+	}
+
+	limit := parquetPageSize
+	if remaining >= 0 {
+		limit = min(limit, remaining)
+	}
+	packets, err := fetch(ctx, 0, limit)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+	w.Header().Set("Content-Disposition", `attachment; filename="packets.parquet"`)
+
+	pw := parquet.NewGenericWriter[parquetPacket](w, parquet.Compression(&parquet.Snappy))
+	rows := make([]parquetPacket, 0, len(packets))
+	for {
+		redactForExport(packets)
+		rows = rows[:0]
+		for _, p := range packets {
+			rows = append(rows, toParquetPacket(p))
+		}
+		if _, err := pw.Write(rows); err != nil {
+			log.Printf("Parquet write error: %v", err)
+			return
+		}
+		if err := pw.Flush(); err != nil {
+			log.Printf("Parquet write error: %v", err)
+			return
+		}
+
+		if remaining >= 0 {
+			remaining -= len(packets)
+		}
+		if len(packets) < limit || remaining == 0 {
+			break
+		}
+		if remaining > 0 {
+			limit = min(limit, remaining)
+		}
+		// Each page gets its own query timeout, since the whole export
+		// can take longer than any one query may. The file is already
+		// partly sent, so an error can only cut it short; without the
+		// footer readers reject it.
+		pageCtx, pageCancel, _ := queryContext(r)
+		packets, err = fetch(pageCtx, packets[len(packets)-1].ID, limit)
+		pageCancel()
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			return
+		}
+	}
+	if err := pw.Close(); err != nil {
+		log.Printf("Parquet write error: %v", err)
+	}
+}
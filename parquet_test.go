@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParquetRoundTrip(t *testing.T) {
+	checked := time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC)
+	in := []PacketInfo{
+		{ID: 2, Version: "IPv4", TotalLength: 60, Flags: "DF", TTL: 64, Protocol: "TCP",
+			SourceIP: "10.0.0.1", DestinationIP: "10.0.0.2", Malicious: 3,
+			ScanDate: "2024-03-01", CheckedAt: checked, SourceSensor: "edge-1"},
+		{ID: 1, Version: "IPv6", TotalLength: 1500, TTL: 1, Protocol: "UDP",
+			SourceIP: "2001:db8::1", DestinationIP: "2001:db8::2", CheckedAt: checked},
+	}
+
+	var buf bytes.Buffer
+	pw := parquet.NewGenericWriter[parquetPacket](&buf)
+	for _, p := range in {
+		if _, err := pw.Write([]parquetPacket{toParquetPacket(p)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := parquet.Read[parquetPacket](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("read %d rows, want 2", len(out))
+	}
+	if got := out[0]; got.ID != 2 || got.TotalLength != 60 || got.Malicious != 3 || *got.Flags != "DF" ||
+		!got.CheckedAt.Equal(checked) || *got.SourceSensor != "edge-1" {
+		t.Errorf("row 0 = %+v", got)
+	}
+	if got := out[0].ScanDate; got == nil || !got.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("scan_date = %v, want 2024-03-01 as a timestamp", got)
+	}
+	if got := out[1]; got.Flags != nil || got.ScanDate != nil || got.SourceSensor != nil {
+		t.Errorf("row 1 nullable columns = %v, %v, %v, want nulls", got.Flags, got.ScanDate, got.SourceSensor)
+	}
+}
+
+// TestParquetExportPages checks the export pages past parquetPageSize and
+// the packet listing's limit.
+func TestParquetExportPages(t *testing.T) {
+	openTestDB(t)
+	ids := insertTestPackets(t, 2*defaultPacketLimit+5)
+	slices.Reverse(ids)
+	defer func(n int) { parquetPageSize = n }(parquetPageSize)
+	parquetPageSize = 30
+
+	for _, tc := range []struct {
+		url  string
+		want []int
+	}{
+		{"/api/packets.parquet", ids},
+		{"/api/packets.parquet?limit=45", ids[:45]},
+		{"/api/packets.parquet?after_id=" + strconv.Itoa(ids[10]), ids[:10]},
+	} {
+		w := httptest.NewRecorder()
+		handlePacketsParquet(w, httptest.NewRequest(http.MethodGet, tc.url, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", tc.url, w.Code, w.Body)
+		}
+		rows, err := parquet.Read[parquetPacket](bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.url, err)
+		}
+		got := make([]int, len(rows))
+		for i, row := range rows {
+			got[i] = int(row.ID)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s: exported %d rows, want %d: %v", tc.url, len(got), len(tc.want), got)
+		}
+	}
+}
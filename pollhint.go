@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Polling endpoints suggest when to ask again with X-Poll-After (seconds):
+// the time until about pollHintTarget new packets are expected at the
+// recent arrival rate, bounded to [pollHintMin, pollHintMax]
+// (POLL_HINT, POLL_HINT_MIN, POLL_HINT_MAX, POLL_HINT_TARGET).
+var (
+	pollHintEnabled bool
+	pollHintMin     = time.Second
+	pollHintMax     = 30 * time.Second
+	pollHintTarget  = 10
+)
+
+const (
+	// pollHintSample is how far back the arrival rate is measured.
+	pollHintSample = time.Minute
+	// pollHintRefresh is how long a measured rate is reused.
+	pollHintRefresh = 5 * time.Second
+)
+
+var arrivalRate struct {
+	mu   sync.Mutex
+	at   time.Time
+	rate float64 // packets per second
+}
+
+// recentArrivalRate returns packets per second over the last
+// pollHintSample, measured at most once per pollHintRefresh.
+func recentArrivalRate(ctx context.Context) (float64, error) {
+	arrivalRate.mu.Lock()
+	defer arrivalRate.mu.Unlock()
+	fresh := time.Since(arrivalRate.at) < pollHintRefresh
+	arrivalRateCounters.record(fresh)
+	if fresh {
+		return arrivalRate.rate, nil
+	}
+
+	var n int
+	err := db().QueryRowContext(ctx, tagQuery(`SELECT COUNT(*) FROM packet_info WHERE checked_at >= $1`),
+		time.Now().Add(-pollHintSample)).Scan(&n)
+	if err != nil {
+		return 0, err
+	}
+	arrivalRate.at = time.Now()
+	arrivalRate.rate = float64(n) / pollHintSample.Seconds()
+	return arrivalRate.rate, nil
+}
+
+// pollAfter is the suggested wait before the next poll at rate.
+func pollAfter(rate float64) time.Duration {
+	if rate <= 0 {
+		return pollHintMax
+	}
+	d := time.Duration(float64(pollHintTarget) / rate * float64(time.Second))
+	return min(max(d, pollHintMin), pollHintMax)
+}
+
+// pollHint adds X-Poll-After to a polling endpoint's responses. Clients are
+// free to ignore it; if the rate can't be measured the header is left out.
+func pollHint(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if pollHintEnabled {
+			if rate, err := recentArrivalRate(r.Context()); err != nil {
+				log.Printf("Poll hint error: %v", err)
+			} else {
+				secs := int(math.Ceil(pollAfter(rate).Seconds()))
+				w.Header().Set("X-Poll-After", strconv.Itoa(secs))
+			}
+		}
+		next(w, r)
+	}
+}
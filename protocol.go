@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Sizes of the two lists in a protocol drill-down.
+const (
+	protocolTopSources = 10
+	protocolRecent     = 20
+)
+
+type sourceCount struct {
+	SourceIP string `json:"source_ip"`
+	Packets  int    `json:"packets"`
+}
+
+// protocolDetail is everything the dashboard's protocol panel shows.
+type protocolDetail struct {
+	Protocol   string        `json:"protocol"`
+	Packets    int           `json:"packets"`
+	Bytes      int64         `json:"bytes"`
+	Malicious  int           `json:"malicious"`
+	TopSources []sourceCount `json:"top_sources"`
+	Recent     []PacketInfo  `json:"recent"`
+}
+
+func getProtocolDetail(ctx context.Context, protocol string) (protocolDetail, error) {
+	d := protocolDetail{Protocol: protocol, TopSources: []sourceCount{}, Recent: []PacketInfo{}}
+
+	err := db().QueryRowContext(ctx, tagQuery(`
+		SELECT COUNT(*), COALESCE(SUM(`+clampedTotalLengthSQL+`), 0),
+		       COUNT(*) FILTER (WHERE malicious > 0)
+		FROM packet_info
+		WHERE protocol = $1
+	`), protocol).Scan(&d.Packets, &d.Bytes, &d.Malicious)
+	if err != nil || d.Packets == 0 {
+		return d, err
+	}
+
+	rows, err := db().QueryContext(ctx, tagQuery(`
+		SELECT source_ip, COUNT(*) AS cnt
+		FROM packet_info
+		WHERE protocol = $1
+		GROUP BY source_ip
+		ORDER BY cnt DESC, source_ip
+		LIMIT $2
+	`), protocol, protocolTopSources)
+	if err != nil {
+		return d, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s sourceCount
+		if err := rows.Scan(&s.SourceIP, &s.Packets); err != nil {
+			return d, err
+		}
+		d.TopSources = append(d.TopSources, s)
+	}
+	if err := rows.Err(); err != nil {
+		return d, err
+	}
+
+	recent, err := getPackets(ctx, packetFilter{Protocol: protocol, Limit: protocolRecent})
+	if err != nil {
+		return d, err
+	}
+	if recent != nil {
+		d.Recent = recent
+	}
+	return d, nil
+}
+
+// handleProtocolAPI serves /api/protocol/{name}: the protocol's totals, top
+// sources and most recent packets in one response, so the detail panel
+// needs a single request.
+func handleProtocolAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	if err := checkFullScan(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	protocol := r.PathValue("name")
+	if protocol == "" {
+		http.Error(w, "Protocol name is required", http.StatusBadRequest)
+		return
+	}
+
+	d, err := getProtocolDetail(ctx, protocol)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+	if d.Packets == 0 {
+		http.Error(w, "No packets with protocol "+protocol, http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, r, d)
+}
+
+type protocolActivity struct {
+	Protocol string    `json:"protocol"`
+	Count    int       `json:"count"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// getProtocolActivity lists up to limit protocols, most recently seen
+// first, with how many of their packets were checked at or after since.
+func getProtocolActivity(ctx context.Context, since time.Time, limit int) ([]protocolActivity, error) {
+	query := `
+		SELECT protocol, COUNT(*) FILTER (WHERE checked_at >= $1), MAX(checked_at) AS last_seen
+		FROM packet_info
+		GROUP BY protocol
+		ORDER BY last_seen DESC, protocol
+		LIMIT $2
+	`
+
+	rows, err := db().QueryContext(ctx, tagQuery(query), since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []protocolActivity{}
+	for rows.Next() {
+		var a protocolActivity
+		if err := rows.Scan(&a.Protocol, &a.Count, &a.LastSeen); err != nil {
+			return nil, err
+		}
+		results = append(results, a)
+	}
+
+	return results, rows.Err()
+}
+
+// handleProtocolsAPI serves /api/protocols for the protocol-activity
+// sidebar; ?window= (default 24h) sets what counts as recent.
+func handleProtocolsAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	// last_seen reads every packet, whatever the window.
+	if err := checkFullScan(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	window, err := windowParam(r, "window", 24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := statsLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := getProtocolActivity(ctx, time.Now().Add(-window), limit)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	writeJSON(w, r, results)
+}
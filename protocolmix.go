@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// protocolMixConfig controls the protocol distribution check
+// (PROTOCOL_MIX_*).
+type protocolMixConfig struct {
+	Window     time.Duration // current window, also the check interval
+	Baseline   time.Duration // rolling baseline preceding the window
+	Threshold  float64       // percentage points of total variation distance
+	MinPackets int           // skip windows with less traffic than this
+}
+
+func getProtocolCounts(ctx context.Context, from, to time.Time) (map[string]int, error) {
+	query := `
+		SELECT protocol, COUNT(*)
+		FROM packet_info
+		WHERE checked_at >= $1 AND checked_at < $2
+		GROUP BY protocol
+	`
+
+	rows, err := db().QueryContext(ctx, tagQuery(query), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var protocol string
+		var n int
+		if err := rows.Scan(&protocol, &n); err != nil {
+			return nil, err
+		}
+		counts[protocol] = n
+	}
+	return counts, rows.Err()
+}
+
+// getIDRange returns the lowest and highest packet id checked in [from, to),
+// which identifies that window's packets for alertKey.
+func getIDRange(ctx context.Context, from, to time.Time) (ids []int, malicious int, err error) {
+	var lo, hi int
+	err = db().QueryRowContext(ctx, tagQuery(`
+		SELECT COALESCE(MIN(id), 0), COALESCE(MAX(id), 0), COUNT(*) FILTER (WHERE malicious > 0)
+		FROM packet_info
+		WHERE checked_at >= $1 AND checked_at < $2
+	`), from, to).Scan(&lo, &hi, &malicious)
+	return []int{lo, hi}, malicious, err
+}
+
+// protocolShares converts counts to percentages of the total.
+func protocolShares(counts map[string]int) (map[string]float64, int) {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	shares := make(map[string]float64, len(counts))
+	for p, n := range counts {
+		if total > 0 {
+			shares[p] = 100 * float64(n) / float64(total)
+		}
+	}
+	return shares, total
+}
+
+// mixDeviation is the total variation distance between two distributions in
+// percentage points: 0 when identical, 100 when disjoint. A UDP share going
+// from 5% to 60% (with the rest shrinking to match) is a deviation of 55.
+func mixDeviation(current, baseline map[string]float64) float64 {
+	sum := 0.0
+	for p, c := range current {
+		sum += math.Abs(c - baseline[p])
+	}
+	for p, b := range baseline {
+		if _, ok := current[p]; !ok {
+			sum += b
+		}
+	}
+	return sum / 2
+}
+
+// runProtocolMixCheck compares each window's protocol mix with the baseline
+// period before it and alerts once when the deviation crosses the threshold,
+// re-arming when the mix returns to normal. Windows are aligned to the
+// epoch, so a check repeated after a restart sees the same packets and its
+// alert the same idempotency key.
+func runProtocolMixCheck(ctx context.Context, cfg protocolMixConfig) {
+	alerting := false
+
+	check := func() error {
+		qctx, cancel := context.WithTimeout(ctx, queryTimeoutMax)
+		defer cancel()
+
+		end := epochTruncate(time.Now(), cfg.Window)
+		current, err := getProtocolCounts(qctx, end.Add(-cfg.Window), end)
+		if err != nil {
+			return err
+		}
+		baseline, err := getProtocolCounts(qctx, end.Add(-cfg.Window-cfg.Baseline), end.Add(-cfg.Window))
+		if err != nil {
+			return err
+		}
+
+		curShares, curTotal := protocolShares(current)
+		baseShares, baseTotal := protocolShares(baseline)
+		if curTotal < cfg.MinPackets || baseTotal < cfg.MinPackets {
+			return nil
+		}
+
+		deviation := mixDeviation(curShares, baseShares)
+		switch {
+		case deviation >= cfg.Threshold && !alerting:
+			alerting = true
+			ids, malicious, err := getIDRange(qctx, end.Add(-cfg.Window), end)
+			if err != nil {
+				return err
+			}
+			// Not qctx: retries may outlast the query timeout.
+			return sendAlert(ctx, Alert{
+				Type:           "protocol_mix_shift",
+				Message:        fmt.Sprintf("Protocol mix deviates %.1f points from the %s baseline", deviation, cfg.Baseline),
+				IdempotencyKey: alertKey("protocol_mix_shift", ids),
+				Malicious:      malicious,
+				Details: map[string]any{
+					"deviation": deviation,
+					"threshold": cfg.Threshold,
+					"window":    cfg.Window.String(),
+					"current":   curShares,
+					"baseline":  baseShares,
+				},
+			})
+		case deviation < cfg.Threshold && alerting:
+			alerting = false
+			log.Printf("Protocol mix back within %.1f points of baseline (%.1f)", cfg.Threshold, deviation)
+		}
+		return nil
+	}
+
+	ticker := time.NewTicker(cfg.Window)
+	defer ticker.Stop()
+	for {
+		if err := check(); err != nil {
+			log.Printf("Protocol mix check error: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/netip"
+)
+
+// deletePacketsByIP removes every packet to or from ip in one transaction,
+// so a failure part-way leaves nothing deleted. raw is the address as the
+// caller wrote it, matched as well in case rows were stored unnormalized.
+func deletePacketsByIP(ctx context.Context, ip, raw string) (int64, error) {
+	tx, err := db().BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, tagQuery(`DELETE FROM packet_info WHERE source_ip IN ($1, $2) OR destination_ip IN ($1, $2)`), ip, raw)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// handleDeleteByIP erases all data for one address (e.g. for a GDPR
+// deletion request). Admin only.
+func handleDeleteByIP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", http.MethodDelete)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	raw := r.URL.Query().Get("ip")
+	addr, err := netip.ParseAddr(raw)
+	if err != nil {
+		http.Error(w, "Invalid ip", http.StatusBadRequest)
+		return
+	}
+	ip := addr.String()
+
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	deleted, err := deletePacketsByIP(ctx, ip, raw)
+	if err != nil {
+		http.Error(w, "Error deleting data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+	// Degraded-mode copies may still hold the erased rows.
+	clearStale()
+	log.Printf("Deleted %d packet(s) for %s at the request of %s", deleted, ip, clientIP(r))
+
+	writeJSON(w, r, map[string]any{"ip": ip, "deleted": deleted})
+}
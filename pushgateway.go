@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// pushClient is used for Pushgateway requests.
+var pushClient = &http.Client{Timeout: 10 * time.Second}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatPushMetrics renders the stats of the last window in the Prometheus
+// text exposition format.
+func formatPushMetrics(window time.Duration, traffic trafficStats, w windowStats) []byte {
+	var buf bytes.Buffer
+	gauge := func(name, help string) {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+	secs := window.Seconds()
+
+	protocols := make([]string, 0, len(traffic.Protocols))
+	for p := range traffic.Protocols {
+		protocols = append(protocols, p)
+	}
+	slices.Sort(protocols)
+
+	gauge("netmon_packets", "Packets checked in the last push interval.")
+	for _, p := range protocols {
+		fmt.Fprintf(&buf, "netmon_packets{protocol=\"%s\"} %d\n", promLabelEscaper.Replace(p), traffic.Protocols[p].Packets)
+	}
+	gauge("netmon_bytes", "Bytes (sum of total_length) checked in the last push interval.")
+	for _, p := range protocols {
+		fmt.Fprintf(&buf, "netmon_bytes{protocol=\"%s\"} %d\n", promLabelEscaper.Replace(p), traffic.Protocols[p].Bytes)
+	}
+	gauge("netmon_packet_rate", "Packets per second over the last push interval.")
+	fmt.Fprintf(&buf, "netmon_packet_rate %g\n", float64(w.TotalPackets)/secs)
+	gauge("netmon_malicious_packets", "Packets with a malicious verdict in the last push interval.")
+	fmt.Fprintf(&buf, "netmon_malicious_packets %d\n", w.Malicious)
+	gauge("netmon_unique_sources", "Distinct source IPs in the last push interval.")
+	fmt.Fprintf(&buf, "netmon_unique_sources %d\n", w.UniqueSources)
+	gauge("netmon_stream_subscribers", "Live (SSE) subscribers connected at push time.")
+	fmt.Fprintf(&buf, "netmon_stream_subscribers %d\n", openStreams())
+
+	return buf.Bytes()
+}
+
+func pushMetrics(ctx context.Context, endpoint string, window time.Duration) error {
+	to := time.Now()
+	from := to.Add(-window)
+
+	traffic, err := getTrafficStats(ctx, &from, 0)
+	if err != nil {
+		return err
+	}
+	w, err := getWindowStats(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(formatPushMetrics(window, traffic, w)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := pushClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("pushgateway returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// runPushgateway pushes the stats of each interval to the Pushgateway at
+// baseURL under the given job, replacing the previous push, until ctx is
+// cancelled.
+func runPushgateway(ctx context.Context, baseURL, job string, interval time.Duration) {
+	endpoint := strings.TrimRight(baseURL, "/") + "/metrics/job/" + url.PathEscape(job)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pushCtx, cancel := context.WithTimeout(ctx, queryTimeout)
+		if err := pushMetrics(pushCtx, endpoint, interval); err != nil {
+			log.Printf("Pushgateway error: %v", err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Bounds on a POST /api/query filter tree.
+const (
+	maxQueryDepth      = 5
+	maxQueryConditions = 50
+)
+
+// queryFieldTypes is the allowlist of packet_info columns the query DSL may
+// reference, keyed by JSON name. Only these names ever reach the SQL text.
+var queryFieldTypes = map[string]string{
+	"id":              "integer",
+	"version":         "string",
+	"total_length":    "integer",
+	"flags":           "string",
+	"ttl":             "integer",
+	"protocol":        "string",
+	"header_checksum": "integer",
+	"source_ip":       "ip",
+	"destination_ip":  "ip",
+	"malicious":       "integer",
+	"suspicious":      "integer",
+	"harmless":        "integer",
+	"undetected":      "integer",
+	"checked_at":      "timestamp",
+	"source_sensor":   "string",
+}
+
+// queryOperators maps DSL operators to SQL, per field type.
+var queryOperators = map[string]map[string]string{
+	"integer":   {"eq": "=", "ne": "<>", "lt": "<", "lte": "<=", "gt": ">", "gte": ">=", "in": "IN"},
+	"timestamp": {"eq": "=", "ne": "<>", "lt": "<", "lte": "<=", "gt": ">", "gte": ">="},
+	"string":    {"eq": "=", "ne": "<>", "in": "IN"},
+	"ip":        {"eq": "=", "ne": "<>", "in": "IN", "within": "<<="},
+}
+
+// queryNode is either a condition (field, op, value) or a group of child
+// nodes joined by and/or.
+type queryNode struct {
+	Field string          `json:"field,omitempty"`
+	Op    string          `json:"op,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+	And   []queryNode     `json:"and,omitempty"`
+	Or    []queryNode     `json:"or,omitempty"`
+}
+
+type queryRequest struct {
+	Filter *queryNode `json:"filter"`
+	Limit  int        `json:"limit"`
+}
+
+type queryCompiler struct {
+	args       []any
+	conditions int
+}
+
+func (c *queryCompiler) compile(n queryNode, depth int) (string, error) {
+	if depth > maxQueryDepth {
+		return "", fmt.Errorf("filter nested deeper than %d levels", maxQueryDepth)
+	}
+
+	isGroup := n.And != nil || n.Or != nil
+	if isGroup && (n.Field != "" || n.Op != "" || n.Value != nil) {
+		return "", fmt.Errorf("a node is either a condition or an and/or group, not both")
+	}
+	if n.And != nil && n.Or != nil {
+		return "", fmt.Errorf("a group has either and or or, not both")
+	}
+	if !isGroup {
+		return c.condition(n)
+	}
+
+	children, joiner := n.And, " AND "
+	if n.Or != nil {
+		children, joiner = n.Or, " OR "
+	}
+	if len(children) == 0 {
+		return "", fmt.Errorf("empty and/or group")
+	}
+	parts := make([]string, len(children))
+	for i, child := range children {
+		sql, err := c.compile(child, depth+1)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = sql
+	}
+	return "(" + strings.Join(parts, joiner) + ")", nil
+}
+
+func (c *queryCompiler) condition(n queryNode) (string, error) {
+	c.conditions++
+	if c.conditions > maxQueryConditions {
+		return "", fmt.Errorf("filter has more than %d conditions", maxQueryConditions)
+	}
+
+	typ, ok := queryFieldTypes[n.Field]
+	if !ok {
+		return "", fmt.Errorf("unknown field %q", n.Field)
+	}
+	op, ok := queryOperators[typ][n.Op]
+	if !ok {
+		return "", fmt.Errorf("operator %q not supported for %s", n.Op, n.Field)
+	}
+	column := n.Field
+	if typ == "ip" && n.Op == "within" {
+		column += "::inet"
+	}
+
+	if n.Op == "in" {
+		var raw []json.RawMessage
+		if err := json.Unmarshal(n.Value, &raw); err != nil || len(raw) == 0 {
+			return "", fmt.Errorf("%s: in expects a non-empty array", n.Field)
+		}
+		if len(raw) > maxIPListLength {
+			return "", fmt.Errorf("%s: too many values for in (max %d)", n.Field, maxIPListLength)
+		}
+		placeholders := make([]string, len(raw))
+		for i, v := range raw {
+			arg, err := queryValue(n.Field, typ, n.Op, v)
+			if err != nil {
+				return "", err
+			}
+			placeholders[i] = c.bind(arg)
+		}
+		return column + " IN (" + strings.Join(placeholders, ", ") + ")", nil
+	}
+
+	arg, err := queryValue(n.Field, typ, n.Op, n.Value)
+	if err != nil {
+		return "", err
+	}
+	return column + " " + op + " " + c.bind(arg), nil
+}
+
+func (c *queryCompiler) bind(arg any) string {
+	c.args = append(c.args, arg)
+	return fmt.Sprintf("$%d", len(c.args))
+}
+
+// queryValue decodes a JSON value and checks it against the field's type.
+func queryValue(field, typ, op string, raw json.RawMessage) (any, error) {
+	if raw == nil {
+		return nil, fmt.Errorf("%s: value is required", field)
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("%s: invalid value", field)
+	}
+
+	switch typ {
+	case "integer":
+		num, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("%s: value must be an integer", field)
+		}
+		n, err := num.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("%s: value must be an integer", field)
+		}
+		return n, nil
+	case "timestamp":
+		s, _ := v.(string)
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: value must be an RFC 3339 timestamp", field)
+		}
+		return t, nil
+	case "ip":
+		s, _ := v.(string)
+		if op == "within" {
+			p, err := netip.ParsePrefix(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: value must be a CIDR prefix", field)
+			}
+			return p.Masked().String(), nil
+		}
+		if _, err := netip.ParseAddr(s); err != nil {
+			return nil, fmt.Errorf("%s: value must be an IP address", field)
+		}
+		return s, nil
+	default:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: value must be a string", field)
+		}
+		return s, nil
+	}
+}
+
+// checkedAtBounds is the checked_at range a valid filter tree can match: the
+// intersection of its and groups and the union of its or groups. A nil bound
+// is open.
+func checkedAtBounds(n queryNode) (from, to *time.Time) {
+	if n.And != nil || n.Or != nil {
+		and := n.And != nil
+		children := n.And
+		if !and {
+			children = n.Or
+		}
+		for i, child := range children {
+			f, t := checkedAtBounds(child)
+			if and {
+				from, to = laterTime(from, f), earlierTime(to, t)
+			} else if i == 0 {
+				from, to = f, t
+			} else {
+				// Any open child leaves the union open.
+				if from != nil && (f == nil || f.Before(*from)) {
+					from = f
+				}
+				if to != nil && (t == nil || t.After(*to)) {
+					to = t
+				}
+			}
+		}
+		return from, to
+	}
+	if n.Field != "checked_at" {
+		return nil, nil
+	}
+	v, err := queryValue(n.Field, "timestamp", n.Op, n.Value)
+	if err != nil {
+		return nil, nil
+	}
+	t := v.(time.Time)
+	switch n.Op {
+	case "gt", "gte":
+		return &t, nil
+	case "lt", "lte":
+		return nil, &t
+	case "eq":
+		return &t, &t
+	}
+	return nil, nil
+}
+
+// laterTime and earlierTime pick between two bounds, nil being open.
+func laterTime(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.After(*a)) {
+		return b
+	}
+	return a
+}
+
+func earlierTime(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.Before(*a)) {
+		return b
+	}
+	return a
+}
+
+// compileQuery turns a filter tree into a WHERE clause with positional args.
+func compileQuery(filter *queryNode) (string, []any, error) {
+	if filter == nil {
+		return "", nil, nil
+	}
+	var c queryCompiler
+	sql, err := c.compile(*filter, 1)
+	if err != nil {
+		return "", nil, err
+	}
+	return "WHERE " + sql, c.args, nil
+}
+
+// handleQueryAPI lists packets matching a JSON filter, e.g.
+//
+//	{"filter": {"or": [{"field": "ttl", "op": "lt", "value": 5},
+//	                   {"field": "source_ip", "op": "within", "value": "10.0.0.0/8"}]},
+//	 "limit": 100}
+func handleQueryAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req queryRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	limit := defaultPacketLimit
+	if req.Limit < 0 {
+		http.Error(w, fmt.Sprintf("invalid limit %d", req.Limit), http.StatusBadRequest)
+		return
+	}
+	if req.Limit > 0 {
+		limit = min(req.Limit, maxPacketLimit)
+	}
+
+	where, args, err := compileQuery(req.Filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Like from/to on /api/packets, a checked_at range without an upper
+	// bound spans to now and one without a lower bound is unbounded.
+	if req.Filter != nil {
+		if from, to := checkedAtBounds(*req.Filter); from != nil || to != nil {
+			if err := checkQueryWindow(r, "checked_at", rangeSpan(from, to)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	packets, err := selectPackets(ctx, where, args, "id DESC", limit)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	writeJSON(w, r, packets)
+}
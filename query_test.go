@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckedAtBounds(t *testing.T) {
+	for _, tc := range []struct {
+		filter   string
+		from, to string
+	}{
+		{`{"field":"ttl","op":"lt","value":5}`, "", ""},
+		{`{"field":"checked_at","op":"gte","value":"2024-01-01T00:00:00Z"}`, "2024-01-01T00:00:00Z", ""},
+		{`{"and":[{"field":"checked_at","op":"gte","value":"2024-01-01T00:00:00Z"},
+			{"field":"checked_at","op":"lt","value":"2024-01-02T00:00:00Z"},
+			{"field":"checked_at","op":"gt","value":"2024-01-01T12:00:00Z"}]}`, "2024-01-01T12:00:00Z", "2024-01-02T00:00:00Z"},
+		{`{"or":[{"field":"checked_at","op":"eq","value":"2024-01-05T00:00:00Z"},
+			{"field":"checked_at","op":"eq","value":"2024-01-01T00:00:00Z"}]}`, "2024-01-01T00:00:00Z", "2024-01-05T00:00:00Z"},
+		{`{"or":[{"field":"checked_at","op":"gte","value":"2024-01-01T00:00:00Z"},
+			{"field":"ttl","op":"lt","value":5}]}`, "", ""},
+	} {
+		var n queryNode
+		if err := json.Unmarshal([]byte(tc.filter), &n); err != nil {
+			t.Fatal(err)
+		}
+		from, to := checkedAtBounds(n)
+		if got := formatBound(from); got != tc.from {
+			t.Errorf("%s: from = %q, want %q", tc.filter, got, tc.from)
+		}
+		if got := formatBound(to); got != tc.to {
+			t.Errorf("%s: to = %q, want %q", tc.filter, got, tc.to)
+		}
+	}
+}
+
+func formatBound(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func TestQueryWindowOpenStart(t *testing.T) {
+	maxQueryWindow = 24 * time.Hour
+	defer func() { maxQueryWindow = 0 }()
+
+	to := time.Now()
+	from := to.Add(-time.Hour)
+	for _, tc := range []struct {
+		url      string
+		from, to *time.Time
+		ok       bool
+	}{
+		{"/api/packets", &from, &to, true},
+		{"/api/packets", &from, nil, true},
+		{"/api/packets", nil, &to, false},
+		{"/api/packets?allow_large=true", nil, &to, false}, // not an admin
+	} {
+		r := httptest.NewRequest(http.MethodGet, tc.url, nil)
+		err := checkQueryWindow(r, "from/to", rangeSpan(tc.from, tc.to))
+		if (err == nil) != tc.ok {
+			t.Errorf("%s from=%v to=%v: err = %v, want ok %v", tc.url, tc.from != nil, tc.to != nil, err, tc.ok)
+		}
+	}
+}
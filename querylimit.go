@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// Aggregate queries scan far more rows than the packet listing, so they
+// take a bigger share of the query semaphore.
+const (
+	readQueryWeight      = 1
+	aggregateQueryWeight = 2
+)
+
+var (
+	querySem          *semaphore.Weighted
+	queryCapacity     int64
+	queryQueueTimeout time.Duration
+)
+
+// limitQueries admits a request only once weight units of database capacity
+// are free (QUERY_CONCURRENCY), waiting up to queryQueueTimeout before
+// answering 503 with Retry-After.
+func limitQueries(weight int64, next http.HandlerFunc) http.HandlerFunc {
+	weight = min(weight, queryCapacity)
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), queryQueueTimeout)
+		defer cancel()
+
+		if err := querySem.Acquire(ctx, weight); err != nil {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(queryQueueTimeout.Seconds()))))
+			http.Error(w, "Too many concurrent queries, try again shortly", http.StatusServiceUnavailable)
+			return
+		}
+		defer querySem.Release(weight)
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// queryComment is prepended to queries as /* ... */ (QUERY_COMMENT) so they
+// can be attributed in pg_stat_statements and pg_stat_activity.
+var queryComment string
+
+// querySettings are applied with SET LOCAL around the packet listing query
+// (QUERY_SET_LOCAL, e.g. "work_mem=64MB,random_page_cost=1.1").
+var querySettings []querySetting
+
+type querySetting struct {
+	Name  string
+	Value string
+}
+
+var settingName = regexp.MustCompile(`^[a-z_][a-z0-9_]*(\.[a-z_][a-z0-9_]*)?$`)
+
+func parseQueryComment(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "*/") || strings.Contains(s, "/*") {
+		return "", fmt.Errorf("QUERY_COMMENT must not contain comment delimiters")
+	}
+	return s, nil
+}
+
+// parseQuerySettings parses a comma-separated list of name=value pairs.
+// Names are restricted to Postgres parameter syntax; values are quoted as
+// literals when applied.
+func parseQuerySettings(s string) ([]querySetting, error) {
+	var settings []querySetting
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || !settingName.MatchString(name) {
+			return nil, fmt.Errorf("invalid QUERY_SET_LOCAL entry %q", part)
+		}
+		settings = append(settings, querySetting{Name: name, Value: strings.TrimSpace(value)})
+	}
+	return settings, nil
+}
+
+func tagQuery(query string) string {
+	if queryComment == "" {
+		return query
+	}
+	return "/* " + queryComment + " */ " + query
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// tunedTx is a transaction from beginTunedQuery, with the pool it runs on.
+type tunedTx struct {
+	*sql.Tx
+	pool *sql.DB
+}
+
+// beginTunedQuery returns where to run a query with querySettings in
+// effect. Without settings that is db itself; otherwise it is a read-only
+// transaction (a tunedTx) the caller must release with done once its rows
+// are closed.
+func beginTunedQuery(ctx context.Context) (q querier, done func(), err error) {
+	pool := db()
+	if len(querySettings) == 0 {
+		return pool, func() {}, nil
+	}
+
+	tx, err := pool.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, s := range querySettings {
+		if _, err := tx.ExecContext(ctx, "SET LOCAL "+s.Name+" = "+pq.QuoteLiteral(s.Value)); err != nil {
+			tx.Rollback()
+			return nil, nil, fmt.Errorf("SET LOCAL %s: %w", s.Name, err)
+		}
+	}
+	return tunedTx{tx, pool}, func() { tx.Rollback() }, nil
+}
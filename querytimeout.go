@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Default and maximum per-request query deadlines (QUERY_TIMEOUT,
+// QUERY_TIMEOUT_MAX).
+var (
+	queryTimeout    time.Duration
+	queryTimeoutMax time.Duration
+)
+
+// queryContext derives the deadline for a request's database work. Clients
+// may ask for a longer (or shorter) deadline with X-Query-Timeout, given as
+// a Go duration ("90s") or whole seconds, up to queryTimeoutMax.
+func queryContext(r *http.Request) (context.Context, context.CancelFunc, error) {
+	timeout := queryTimeout
+	if s := r.Header.Get("X-Query-Timeout"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			secs, convErr := strconv.Atoi(s)
+			if convErr != nil {
+				return nil, nil, fmt.Errorf("invalid X-Query-Timeout %q", s)
+			}
+			d = time.Duration(secs) * time.Second
+		}
+		if d <= 0 {
+			return nil, nil, fmt.Errorf("invalid X-Query-Timeout %q", s)
+		}
+		if d > queryTimeoutMax {
+			return nil, nil, fmt.Errorf("X-Query-Timeout %s exceeds maximum of %s", d, queryTimeoutMax)
+		}
+		timeout = d
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	return ctx, cancel, nil
+}
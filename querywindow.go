@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxQueryWindow caps the time span a request may scan (MAX_QUERY_WINDOW).
+// Zero means no cap. Admins can lift it per request with allow_large=true.
+var maxQueryWindow time.Duration
+
+// unboundedSpan is the span of a range with no start, which any
+// MAX_QUERY_WINDOW rejects.
+const unboundedSpan = time.Duration(math.MaxInt64)
+
+// checkQueryWindow rejects a span longer than maxQueryWindow unless the
+// request is an admin's with allow_large=true.
+func checkQueryWindow(r *http.Request, what string, span time.Duration) error {
+	if maxQueryWindow == 0 || span <= maxQueryWindow {
+		return nil
+	}
+	if large, _ := strconv.ParseBool(r.URL.Query().Get("allow_large")); large && isAdmin(r) {
+		return nil
+	}
+	if span == unboundedSpan {
+		return fmt.Errorf("%s has no start, so it exceeds the %s maximum (admins may pass allow_large=true)", what, maxQueryWindow)
+	}
+	return fmt.Errorf("%s spans %s, more than the %s maximum (admins may pass allow_large=true)", what, span, maxQueryWindow)
+}
+
+// checkFullScan is checkQueryWindow for endpoints that aggregate every
+// packet, with no window to narrow them.
+func checkFullScan(r *http.Request) error {
+	return checkQueryWindow(r, "this aggregate covers all packets and", unboundedSpan)
+}
+
+// windowParam is durationParam for parameters that set how far back a
+// query scans. An explicit value over maxQueryWindow is an error; the
+// default is shortened to fit.
+func windowParam(r *http.Request, key string, def time.Duration) (time.Duration, error) {
+	if r.URL.Query().Get(key) == "" {
+		if maxQueryWindow > 0 {
+			def = min(def, maxQueryWindow)
+		}
+		return def, nil
+	}
+	d, err := durationParam(r, key, def)
+	if err != nil {
+		return 0, err
+	}
+	return d, checkQueryWindow(r, key, d)
+}
+
+// rangeSpan is the length of a time range whose missing end is now and
+// whose missing start makes it unbounded.
+func rangeSpan(from, to *time.Time) time.Duration {
+	if from == nil {
+		return unboundedSpan
+	}
+	end := time.Now()
+	if to != nil {
+		end = *to
+	}
+	return end.Sub(*from)
+}
+
+// optionalWindowParam is windowParam for parameters whose absence means the
+// whole period, returning 0 for it. Under MAX_QUERY_WINDOW the whole period
+// is out of bounds like any long span, so the default becomes the cap.
+func optionalWindowParam(r *http.Request, key string) (time.Duration, error) {
+	if r.URL.Query().Get(key) == "" && checkQueryWindow(r, key, unboundedSpan) != nil {
+		return maxQueryWindow, nil
+	}
+	return windowParam(r, key, 0)
+}
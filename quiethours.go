@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// During quiet hours (QUIET_HOURS, e.g. "22:00-07:00,12:30-13:00", read in
+// QUIET_HOURS_TZ) only critical alerts are delivered: ones involving at
+// least alertCriticalMalicious malicious packets (ALERT_CRITICAL_MALICIOUS).
+// The rest are held and sent as one summary once quiet hours end.
+var (
+	quietHours             []quietWindow
+	quietHoursLocation     = time.Local
+	alertCriticalMalicious = 10
+)
+
+// maxHeldAlerts bounds the alerts held for the summary; later ones are
+// only counted.
+const maxHeldAlerts = 100
+
+// quietWindow is a daily window in minutes after midnight. A window whose
+// end is before its start runs past midnight.
+type quietWindow struct {
+	start, end int
+}
+
+func (q quietWindow) contains(minute int) bool {
+	if q.start <= q.end {
+		return minute >= q.start && minute < q.end
+	}
+	return minute >= q.start || minute < q.end
+}
+
+// parseQuietHours parses comma-separated "HH:MM-HH:MM" windows.
+func parseQuietHours(s string) ([]quietWindow, error) {
+	var windows []quietWindow
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(part, "-")
+		start, err1 := time.Parse("15:04", strings.TrimSpace(from))
+		end, err2 := time.Parse("15:04", strings.TrimSpace(to))
+		if !ok || err1 != nil || err2 != nil || start.Equal(end) {
+			return nil, fmt.Errorf("invalid QUIET_HOURS window %q (want HH:MM-HH:MM)", part)
+		}
+		windows = append(windows, quietWindow{
+			start: start.Hour()*60 + start.Minute(),
+			end:   end.Hour()*60 + end.Minute(),
+		})
+	}
+	return windows, nil
+}
+
+func inQuietHours(t time.Time) bool {
+	t = t.In(quietHoursLocation)
+	minute := t.Hour()*60 + t.Minute()
+	for _, q := range quietHours {
+		if q.contains(minute) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCriticalAlert(a Alert) bool {
+	return a.Malicious >= alertCriticalMalicious
+}
+
+var heldAlerts struct {
+	mu      sync.Mutex
+	alerts  []Alert
+	dropped int
+}
+
+// holdAlert queues a for the end-of-quiet-hours summary.
+func holdAlert(a Alert) {
+	heldAlerts.mu.Lock()
+	defer heldAlerts.mu.Unlock()
+	if len(heldAlerts.alerts) < maxHeldAlerts {
+		heldAlerts.alerts = append(heldAlerts.alerts, a)
+	} else {
+		heldAlerts.dropped++
+	}
+}
+
+// takeHeldAlerts empties the queue.
+func takeHeldAlerts() ([]Alert, int) {
+	heldAlerts.mu.Lock()
+	defer heldAlerts.mu.Unlock()
+	alerts, dropped := heldAlerts.alerts, heldAlerts.dropped
+	heldAlerts.alerts, heldAlerts.dropped = nil, 0
+	return alerts, dropped
+}
+
+// summarizeHeldAlerts builds the alert sent for what quiet hours held back.
+func summarizeHeldAlerts(alerts []Alert, dropped int) Alert {
+	byType := map[string]int{}
+	keys := make([]string, len(alerts))
+	for i, a := range alerts {
+		byType[a.Type]++
+		keys[i] = a.IdempotencyKey
+	}
+	for range dropped {
+		byType["other"]++
+	}
+	total := len(alerts) + dropped
+	return Alert{
+		Type:           "quiet_hours_summary",
+		Message:        fmt.Sprintf("%d alert(s) held during quiet hours", total),
+		Time:           time.Now(),
+		IdempotencyKey: alertKey("quiet_hours_summary\x00"+strings.Join(keys, "\x00"), nil),
+		Details: map[string]any{
+			"count":   total,
+			"by_type": byType,
+			"alerts":  alerts,
+		},
+	}
+}
+
+// runQuietHoursSummary sends the held alerts as one summary whenever a
+// check finds quiet hours over, until ctx is cancelled.
+func runQuietHoursSummary(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if inQuietHours(time.Now()) {
+			continue
+		}
+		alerts, dropped := takeHeldAlerts()
+		if len(alerts) == 0 && dropped == 0 {
+			continue
+		}
+		if err := deliverAlert(ctx, summarizeHeldAlerts(alerts, dropped)); err != nil {
+			log.Printf("Quiet hours summary error: %v", err)
+		}
+	}
+}
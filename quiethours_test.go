@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInQuietHours(t *testing.T) {
+	windows, err := parseQuietHours("22:00-07:00, 12:30-13:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	quietHours, quietHoursLocation = windows, time.UTC
+	defer func() { quietHours, quietHoursLocation = nil, time.Local }()
+
+	for _, tc := range []struct {
+		at   string
+		want bool
+	}{
+		{"21:59", false},
+		{"22:00", true},
+		{"03:00", true},
+		{"07:00", false},
+		{"12:45", true},
+		{"13:00", false},
+	} {
+		at, _ := time.Parse("15:04", tc.at)
+		if got := inQuietHours(at); got != tc.want {
+			t.Errorf("inQuietHours(%s) = %v, want %v", tc.at, got, tc.want)
+		}
+	}
+
+	for _, bad := range []string{"22:00", "25:00-07:00", "07:00-07:00"} {
+		if _, err := parseQuietHours(bad); err == nil {
+			t.Errorf("parseQuietHours(%q) succeeded", bad)
+		}
+	}
+}
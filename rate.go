@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxRateSmooth bounds the moving-average width of /api/rate in buckets.
+const maxRateSmooth = 100
+
+type ratePoint struct {
+	Bucket   time.Time `json:"bucket"`
+	Packets  int       `json:"packets"`
+	Rate     float64   `json:"rate"`     // packets per second in this bucket
+	Smoothed float64   `json:"smoothed"` // moving average of rate
+}
+
+// getPacketCounts counts packets in n consecutive interval-sized buckets
+// starting at first (which must be bucket-aligned), zero-filling gaps.
+func getPacketCounts(ctx context.Context, first time.Time, interval time.Duration, n int) ([]int, error) {
+	query := `
+		SELECT ` + timeBucketSQL + ` AS bucket, COUNT(*)
+		FROM packet_info
+		WHERE checked_at >= $2 AND checked_at < $3
+		GROUP BY bucket
+	`
+
+	end := first.Add(time.Duration(n) * interval)
+	rows, err := db().QueryContext(ctx, tagQuery(query), interval.Seconds(), first, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make([]int, n)
+	for rows.Next() {
+		var bucket time.Time
+		var count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		if i := int(epochTruncate(bucket, interval).Sub(first) / interval); i >= 0 && i < n {
+			counts[i] = count
+		}
+	}
+	return counts, rows.Err()
+}
+
+// getSmoothedRate returns per-bucket packet rates over the window with a
+// trailing moving average of smooth buckets. Buckets before the window are
+// fetched as well, so the first points are averaged over full width too.
+func getSmoothedRate(ctx context.Context, window, interval time.Duration, smooth, limit int) ([]ratePoint, error) {
+	last := epochTruncate(time.Now(), interval)
+	first := epochTruncate(time.Now().Add(-window), interval)
+	n := min(int(last.Sub(first)/interval)+1, limit)
+	first = last.Add(-time.Duration(n-1) * interval)
+
+	counts, err := getPacketCounts(ctx, first.Add(-time.Duration(smooth-1)*interval), interval, n+smooth-1)
+	if err != nil {
+		return nil, err
+	}
+
+	perSecond := func(c int) float64 { return float64(c) / interval.Seconds() }
+	points := make([]ratePoint, n)
+	sum := 0
+	for i, c := range counts {
+		sum += c
+		if i >= smooth {
+			sum -= counts[i-smooth]
+		}
+		if j := i - (smooth - 1); j >= 0 {
+			points[j] = ratePoint{
+				Bucket:   first.Add(time.Duration(j) * interval),
+				Packets:  c,
+				Rate:     perSecond(c),
+				Smoothed: perSecond(sum) / float64(smooth),
+			}
+		}
+	}
+	return points, nil
+}
+
+// handleRateAPI serves /api/rate?window=1m&interval=1s&smooth=5: packet
+// rates per interval bucket with a moving average over smooth buckets, for
+// graphs that would be unreadably spiky otherwise.
+func handleRateAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	interval, err := durationParam(r, "interval", time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if interval < time.Second || interval%time.Second != 0 {
+		http.Error(w, "interval must be a whole number of seconds", http.StatusBadRequest)
+		return
+	}
+	window, err := windowParam(r, "window", time.Minute)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	smooth := 1
+	if s := r.URL.Query().Get("smooth"); s != "" {
+		smooth, err = strconv.Atoi(s)
+		if err != nil || smooth <= 0 || smooth > maxRateSmooth {
+			http.Error(w, fmt.Sprintf("invalid smooth %q (want 1-%d buckets)", s, maxRateSmooth), http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit, err := statsLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	points, err := getSmoothedRate(ctx, window, interval, smooth, limit)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	writeJSON(w, r, map[string]any{
+		"window":   window.String(),
+		"interval": interval.String(),
+		"smooth":   smooth,
+		"points":   points,
+	})
+}
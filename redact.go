@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// exportRedactFields are masked in data export endpoints (Wireshark CSV,
+// Parquet) but not in the live API or dashboard (EXPORT_REDACT_FIELDS), for
+// data that leaves the building.
+var exportRedactFields map[string]bool
+
+// Prefix lengths IP addresses are truncated to when redacted.
+const (
+	redactIPv4Bits = 24
+	redactIPv6Bits = 48
+)
+
+// redactableFields are the PacketInfo JSON names EXPORT_REDACT_FIELDS may list.
+var redactableFields = []string{"source_ip", "destination_ip", "source_sensor"}
+
+func parseRedactFields(s string) (map[string]bool, error) {
+	fields := map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(redactableFields, name) {
+			return nil, fmt.Errorf("invalid EXPORT_REDACT_FIELDS entry %q (want %s)", name, strings.Join(redactableFields, ", "))
+		}
+		fields[name] = true
+	}
+	return fields, nil
+}
+
+// redactIP keeps only the network part of ip, e.g. 192.0.2.57 becomes
+// 192.0.2.0, so exports still group by subnet.
+func redactIP(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "redacted"
+	}
+	bits := redactIPv6Bits
+	if addr.Is4() || addr.Is4In6() {
+		addr, bits = addr.Unmap(), redactIPv4Bits
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return "redacted"
+	}
+	return prefix.Addr().String()
+}
+
+// redactForExport masks exportRedactFields in packets in place.
+func redactForExport(packets []PacketInfo) {
+	if len(exportRedactFields) == 0 {
+		return
+	}
+	for i := range packets {
+		p := &packets[i]
+		if exportRedactFields["source_ip"] {
+			p.SourceIP = redactIP(p.SourceIP)
+		}
+		if exportRedactFields["destination_ip"] {
+			p.DestinationIP = redactIP(p.DestinationIP)
+		}
+		if exportRedactFields["source_sensor"] && p.SourceSensor != "" {
+			p.SourceSensor = "redacted"
+		}
+	}
+}
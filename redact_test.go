@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedactIP(t *testing.T) {
+	for _, tc := range []struct {
+		ip, want string
+	}{
+		{"192.0.2.57", "192.0.2.0"},
+		{"10.0.0.1", "10.0.0.0"},
+		{"2001:db8:abcd:12::1", "2001:db8:abcd::"},
+		{"fe80::1%eth0", "fe80::"},
+		// IPv4-mapped IPv6 is redacted like the IPv4 address it carries.
+		{"::ffff:192.0.2.57", "192.0.2.0"},
+		{"", "redacted"},
+		{"not-an-ip", "redacted"},
+		{"192.0.2.300", "redacted"},
+		{"192.0.2.0/24", "redacted"},
+	} {
+		if got := redactIP(tc.ip); got != tc.want {
+			t.Errorf("redactIP(%q) = %q, want %q", tc.ip, got, tc.want)
+		}
+	}
+}
+
+func TestRedactForExport(t *testing.T) {
+	defer func(f map[string]bool) { exportRedactFields = f }(exportRedactFields)
+
+	packets := func() []PacketInfo {
+		return []PacketInfo{
+			{SourceIP: "192.0.2.57", DestinationIP: "2001:db8:abcd:12::1", SourceSensor: "edge-1"},
+			{SourceIP: "bogus", DestinationIP: "198.51.100.7"},
+		}
+	}
+
+	exportRedactFields = nil
+	got := packets()
+	redactForExport(got)
+	if !reflect.DeepEqual(got, packets()) {
+		t.Errorf("no fields configured: packets changed to %+v", got)
+	}
+
+	exportRedactFields = map[string]bool{"source_ip": true, "source_sensor": true}
+	got = packets()
+	redactForExport(got)
+	want := []PacketInfo{
+		{SourceIP: "192.0.2.0", DestinationIP: "2001:db8:abcd:12::1", SourceSensor: "redacted"},
+		{SourceIP: "redacted", DestinationIP: "198.51.100.7"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("redacted packets = %+v, want %+v", got, want)
+	}
+}
+
+// TestRedactExportOnly checks EXPORT_REDACT_FIELDS masks the CSV export but
+// leaves /api/packets alone.
+func TestRedactExportOnly(t *testing.T) {
+	openTestDB(t)
+	insertTestPackets(t, 3)
+	defer func(f map[string]bool) { exportRedactFields = f }(exportRedactFields)
+	exportRedactFields = map[string]bool{"source_ip": true, "destination_ip": true}
+
+	w := httptest.NewRecorder()
+	handlePacketsAPI(w, httptest.NewRequest(http.MethodGet, "/api/packets", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("/api/packets: status %d: %s", w.Code, w.Body)
+	}
+	var packets []PacketInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &packets); err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != 3 {
+		t.Fatalf("/api/packets returned %d packets, want 3", len(packets))
+	}
+	for _, p := range packets {
+		if p.SourceIP != "10.0.0.1" || p.DestinationIP != "10.0.0.2" {
+			t.Errorf("/api/packets masked packet %d: %s -> %s", p.ID, p.SourceIP, p.DestinationIP)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	handleWiresharkCSV(w, httptest.NewRequest(http.MethodGet, "/api/packets/wireshark.csv", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("CSV export: status %d: %s", w.Code, w.Body)
+	}
+	if body := w.Body.String(); strings.Contains(body, "10.0.0.1") || !strings.Contains(body, "10.0.0.0") {
+		t.Errorf("CSV export not redacted:\n%s", body)
+	}
+}
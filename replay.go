@@ -0,0 +1,107 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxReplayGap caps the (scaled) pause between two replayed packets so a
+// quiet period in the data doesn't stall the stream indefinitely.
+const maxReplayGap = 30 * time.Second
+
+// fetchReplayPage loads the page of a replay after filter's cursor, with
+// a fresh query timeout since the stream can outlast any one of them.
+func fetchReplayPage(r *http.Request, filter packetFilter) ([]PacketInfo, error) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	return queryPackets(ctx, filter, "checked_at, id")
+}
+
+// handleReplayAPI streams stored packets between from and to over SSE,
+// spaced by their original checked_at deltas divided by speed. The window
+// is read a page at a time along a (checked_at, id) keyset, so replays of
+// any length run to the end.
+func handleReplayAPI(w http.ResponseWriter, r *http.Request) {
+	filter, err := parsePacketFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.CheckedFrom == nil {
+		http.Error(w, "from is required", http.StatusBadRequest)
+		return
+	}
+
+	speed := 1.0
+	if s := r.URL.Query().Get("speed"); s != "" {
+		speed, err = strconv.ParseFloat(s, 64)
+		if err != nil || speed <= 0 || speed > 1000 {
+			http.Error(w, "Invalid speed (0-1000]", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.Limit = maxPacketLimit
+	packets, err := queryPackets(ctx, filter, "checked_at, id")
+	cancel()
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	flusher, ok := startSSE(w)
+	if !ok {
+		return
+	}
+
+	timer := time.NewTimer(maxReplayGap)
+	timer.Stop()
+	defer timer.Stop()
+
+	var prev *PacketInfo
+	count := 0
+	for len(packets) > 0 {
+		for i := range packets {
+			p := &packets[i]
+			if prev != nil {
+				gap := time.Duration(float64(p.CheckedAt.Sub(prev.CheckedAt)) / speed)
+				timer.Reset(min(max(gap, 0), maxReplayGap))
+				select {
+				case <-r.Context().Done():
+					return
+				case <-timer.C:
+				}
+			}
+			if err := writeSSE(w, flusher, "packet", p); err != nil {
+				return
+			}
+			prev = p
+			count++
+		}
+		if len(packets) < filter.Limit {
+			break
+		}
+
+		filter.Cursor = &packetCursor{CheckedAt: prev.CheckedAt, ID: prev.ID}
+		next, err := fetchReplayPage(r, filter)
+		if err != nil {
+			// Headers are out; tell the client the replay stopped short.
+			log.Printf("Database error: %v", err)
+			writeSSE(w, flusher, "error", map[string]any{"error": "Error fetching data", "count": count})
+			return
+		}
+		packets = next
+	}
+	writeSSE(w, flusher, "end", map[string]int{"count": count})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Reputation is a threat verdict for a single IP address.
+type Reputation struct {
+	Malicious  int       `json:"malicious"`
+	Suspicious int       `json:"suspicious"`
+	Harmless   int       `json:"harmless"`
+	Undetected int       `json:"undetected"`
+	ScanDate   time.Time `json:"scan_date"`
+}
+
+// ThreatProvider is a threat-intel service that can look up the current
+// verdict for an IP. See threatintel.go for the available providers.
+type ThreatProvider interface {
+	Name() string
+	Lookup(ctx context.Context, ip string) (Reputation, error)
+}
+
+// reputation is nil when no provider is configured (THREAT_PROVIDERS).
+var reputation ThreatProvider
+
+// rescanLimiter spaces out calls to the external service.
+var rescanLimiter *intervalLimiter
+
+type virusTotalClient struct {
+	apiKey string
+	client *http.Client
+}
+
+func newVirusTotalClient(apiKey string) *virusTotalClient {
+	return &virusTotalClient{apiKey: apiKey, client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (vt *virusTotalClient) Name() string { return "virustotal" }
+
+func (vt *virusTotalClient) Lookup(ctx context.Context, ip string) (Reputation, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"https://www.virustotal.com/api/v3/ip_addresses/"+url.PathEscape(ip), nil)
+	if err != nil {
+		return Reputation{}, err
+	}
+	req.Header.Set("x-apikey", vt.apiKey)
+
+	resp, err := vt.client.Do(req)
+	if err != nil {
+		return Reputation{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Reputation{}, fmt.Errorf("virustotal: unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Attributes struct {
+				LastAnalysisDate  int64 `json:"last_analysis_date"`
+				LastAnalysisStats struct {
+					Malicious  int `json:"malicious"`
+					Suspicious int `json:"suspicious"`
+					Harmless   int `json:"harmless"`
+					Undetected int `json:"undetected"`
+				} `json:"last_analysis_stats"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Reputation{}, fmt.Errorf("virustotal: %w", err)
+	}
+
+	attrs := body.Data.Attributes
+	rep := Reputation{
+		Malicious:  attrs.LastAnalysisStats.Malicious,
+		Suspicious: attrs.LastAnalysisStats.Suspicious,
+		Harmless:   attrs.LastAnalysisStats.Harmless,
+		Undetected: attrs.LastAnalysisStats.Undetected,
+		ScanDate:   time.Now(),
+	}
+	if attrs.LastAnalysisDate > 0 {
+		rep.ScanDate = time.Unix(attrs.LastAnalysisDate, 0)
+	}
+	return rep, nil
+}
+
+// intervalLimiter allows one event per interval.
+type intervalLimiter struct {
+	mu    sync.Mutex
+	every time.Duration
+	next  time.Time
+}
+
+// reserve claims the next slot, or reports how long until one is free.
+func (l *intervalLimiter) reserve() (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.next) {
+		return l.next.Sub(now), false
+	}
+	l.next = now.Add(l.every)
+	return 0, true
+}
+
+func updateVerdicts(ctx context.Context, ip string, rep Reputation) (int64, error) {
+	query := `
+		UPDATE packet_info
+		SET malicious = $1, suspicious = $2, harmless = $3, undetected = $4, scan_date = $5
+		WHERE source_ip = $6 OR destination_ip = $6
+	`
+
+	res, err := db().ExecContext(ctx, tagQuery(query), rep.Malicious, rep.Suspicious, rep.Harmless, rep.Undetected, rep.ScanDate, ip)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func handleRescanAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if reputation == nil {
+		http.Error(w, "No threat provider configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	ip := r.URL.Query().Get("ip")
+	if net.ParseIP(ip) == nil {
+		http.Error(w, "Invalid ip", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	if wait, ok := rescanLimiter.reserve(); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "Rescan rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	rep, err := reputation.Lookup(ctx, ip)
+	if err != nil {
+		http.Error(w, "Reputation lookup failed", http.StatusBadGateway)
+		log.Printf("Reputation lookup for %s failed: %v", ip, err)
+		return
+	}
+
+	updated, err := updateVerdicts(ctx, ip, rep)
+	if err != nil {
+		http.Error(w, "Error updating data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	writeJSON(w, r, map[string]any{
+		"ip":      ip,
+		"verdict": rep,
+		"updated": updated,
+	})
+}
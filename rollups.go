@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const createRollupsTable = `
+	CREATE TABLE IF NOT EXISTS hourly_rollups (
+		hour     timestamptz NOT NULL,
+		protocol text        NOT NULL,
+		packets  bigint      NOT NULL,
+		bytes    bigint      NOT NULL,
+		PRIMARY KEY (hour, protocol)
+	)
+`
+
+// raw_purged marks hours whose raw packets purgeRawPackets has deleted;
+// their rollups can no longer be recomputed, only added to.
+const addRollupsPurgedColumn = `
+	ALTER TABLE hourly_rollups ADD COLUMN IF NOT EXISTS raw_purged boolean NOT NULL DEFAULT false
+`
+
+// computeRollups (re)aggregates every hour from the one containing since up
+// to now into hourly_rollups. Hours are recomputed in full, so the current,
+// still-filling hour is corrected on the next run.
+func computeRollups(ctx context.Context, since time.Time) (int64, error) {
+	query := `
+		INSERT INTO hourly_rollups (hour, protocol, packets, bytes)
+		SELECT date_trunc('hour', checked_at), protocol, COUNT(*), COALESCE(SUM(` + clampedTotalLengthSQL + `), 0)
+		FROM packet_info
+		WHERE checked_at >= date_trunc('hour', $1::timestamptz)
+		GROUP BY 1, 2
+		ON CONFLICT (hour, protocol)
+		DO UPDATE SET packets = EXCLUDED.packets, bytes = EXCLUDED.bytes
+		WHERE NOT hourly_rollups.raw_purged
+	`
+
+	res, err := db().ExecContext(ctx, tagQuery(query), since)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// purgeRawPackets deletes raw packets checked before the hour containing
+// before and folds them into hourly_rollups in the same statement, so no
+// packet is dropped without being counted. Hours that still had all their
+// rows are replaced with the exact totals; late rows for hours purged
+// earlier are added to what is there.
+func purgeRawPackets(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		WITH purged AS (
+			DELETE FROM packet_info
+			WHERE checked_at < date_trunc('hour', $1::timestamptz)
+			RETURNING checked_at, protocol, total_length
+		)
+		INSERT INTO hourly_rollups (hour, protocol, packets, bytes, raw_purged)
+		SELECT date_trunc('hour', checked_at), protocol, COUNT(*), COALESCE(SUM(` + clampedTotalLengthSQL + `), 0), true
+		FROM purged
+		GROUP BY 1, 2
+		ON CONFLICT (hour, protocol)
+		DO UPDATE SET
+			packets = CASE WHEN hourly_rollups.raw_purged THEN hourly_rollups.packets + EXCLUDED.packets ELSE EXCLUDED.packets END,
+			bytes = CASE WHEN hourly_rollups.raw_purged THEN hourly_rollups.bytes + EXCLUDED.bytes ELSE EXCLUDED.bytes END,
+			raw_purged = true
+	`
+
+	res, err := db().ExecContext(ctx, tagQuery(query), before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if n > 0 {
+		// Kept responses may still count the deleted packets.
+		clearStale()
+	}
+	return n, err
+}
+
+func purgeRollups(ctx context.Context, before time.Time) (int64, error) {
+	res, err := db().ExecContext(ctx, tagQuery(`DELETE FROM hourly_rollups WHERE hour < $1`), before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// runRollups refreshes the rollups for the previous and current hour every
+// interval and drops rollups older than retention, until ctx is cancelled.
+// With rawRetention > 0 it also downsamples raw packets older than that
+// into rollups (purgeRawPackets).
+func runRollups(ctx context.Context, interval, retention, rawRetention time.Duration) {
+	if _, err := db().ExecContext(ctx, createRollupsTable); err != nil {
+		log.Printf("Rollups disabled, could not create hourly_rollups: %v", err)
+		return
+	}
+	if _, err := db().ExecContext(ctx, addRollupsPurgedColumn); err != nil {
+		log.Printf("Rollups disabled, could not migrate hourly_rollups: %v", err)
+		return
+	}
+
+	run := func() {
+		now := time.Now()
+		if n, err := computeRollups(ctx, now.Add(-time.Hour)); err != nil {
+			log.Printf("Rollup error: %v", err)
+		} else {
+			log.Printf("Rollups updated (%d rows)", n)
+		}
+		if rawRetention > 0 {
+			if n, err := purgeRawPackets(ctx, now.Add(-rawRetention)); err != nil {
+				log.Printf("Raw retention error: %v", err)
+			} else if n > 0 {
+				log.Printf("Raw packets older than %s folded into %d rollup row(s)", rawRetention, n)
+			}
+		}
+		if _, err := purgeRollups(ctx, now.Add(-retention)); err != nil {
+			log.Printf("Rollup retention error: %v", err)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		run()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type rollup struct {
+	Hour     time.Time `json:"hour"`
+	Protocol string    `json:"protocol"`
+	Packets  int64     `json:"packets"`
+	Bytes    int64     `json:"bytes"`
+}
+
+func getRollups(ctx context.Context, since time.Time, protocol string, limit int) ([]rollup, error) {
+	query := `
+		SELECT hour, protocol, packets, bytes
+		FROM hourly_rollups
+		WHERE hour >= $1 AND ($2 = '' OR protocol = $2)
+		ORDER BY hour DESC, protocol DESC
+		LIMIT $3
+	`
+
+	rows, err := db().QueryContext(ctx, tagQuery(query), since, protocol, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []rollup{}
+	for rows.Next() {
+		var ru rollup
+		if err := rows.Scan(&ru.Hour, &ru.Protocol, &ru.Packets, &ru.Bytes); err != nil {
+			return nil, err
+		}
+		results = append(results, ru)
+	}
+
+	// Fetched newest first so the limit keeps the most recent hours.
+	slices.Reverse(results)
+	return results, rows.Err()
+}
+
+func handleTrendsAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	window, err := durationParam(r, "window", 7*24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := statsLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := getRollups(ctx, time.Now().Add(-window), r.URL.Query().Get("protocol"), limit)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	writeJSON(w, r, results)
+}
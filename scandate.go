@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scanDateLayout is how scan_date is rendered: a bare date by default, or
+// a full RFC 3339 timestamp keeping the time of day (SCAN_DATE_FORMAT).
+var scanDateLayout = "2006-01-02"
+
+// parseScanDateFormat maps SCAN_DATE_FORMAT ("date" or "rfc3339") to a
+// layout.
+func parseScanDateFormat(s string) (string, error) {
+	switch s {
+	case "", "date":
+		return "2006-01-02", nil
+	case "rfc3339":
+		return time.RFC3339, nil
+	}
+	return "", fmt.Errorf("invalid SCAN_DATE_FORMAT %q (want date or rfc3339)", s)
+}
+
+// Layouts tried, in order, when scan_date is stored as text.
+var scanDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseScanDate interprets a raw scan_date column value, which depending on
+// the ingest pipeline may be a date/timestamp, Unix seconds or milliseconds,
+// or a text timestamp.
+func parseScanDate(v any) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case int64:
+		return unixScanDate(v), true
+	case float64:
+		return unixScanDate(int64(v)), true
+	case []byte:
+		return parseScanDateString(string(v))
+	case string:
+		return parseScanDateString(v)
+	}
+	return time.Time{}, false
+}
+
+func parseScanDateString(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return unixScanDate(n), true
+	}
+	for _, layout := range scanDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// scanDateSQL converts scan_date to timestamptz in SQL the way
+// parseScanDate does in Go, whatever the column's type: numbers are Unix
+// seconds or milliseconds, text without a zone offset is UTC, and text that
+// isn't a timestamp becomes NULL.
+const scanDateSQL = `(CASE
+	WHEN btrim(scan_date::text) ~ '^-?[0-9]+(\.[0-9]+)?$' THEN to_timestamp(CASE
+		WHEN btrim(scan_date::text)::numeric > 1e11 THEN btrim(scan_date::text)::numeric / 1000
+		ELSE btrim(scan_date::text)::numeric END)
+	WHEN btrim(scan_date::text) ~ '[0-9]:[0-9]{2}(:[0-9]{2}(\.[0-9]+)?)?\s*(Z|[+-][0-9]{2}(:?[0-9]{2})?)$'
+		THEN btrim(scan_date::text)::timestamptz
+	WHEN btrim(scan_date::text) ~ '^[0-9]{4}-[0-9]{2}-[0-9]{2}([T ][0-9]{2}:[0-9]{2}(:[0-9]{2}(\.[0-9]+)?)?)?$'
+		THEN btrim(scan_date::text)::timestamp AT TIME ZONE 'UTC'
+END)`
+
+// unixScanDate treats values too large for seconds as milliseconds.
+func unixScanDate(n int64) time.Time {
+	if n > 1e11 {
+		return time.UnixMilli(n)
+	}
+	return time.Unix(n, 0)
+}
+
+// formatScanDate renders scan_date with scanDateLayout, falling back to the raw
+// value (with a warning) when it can't be parsed.
+func formatScanDate(id int, v any) string {
+	if v == nil {
+		return ""
+	}
+	if t, ok := parseScanDate(v); ok {
+		return t.Format(scanDateLayout)
+	}
+
+	raw := fmt.Sprint(v)
+	if b, ok := v.([]byte); ok {
+		raw = string(b)
+	}
+	log.Printf("Warning: packet %d has unparseable scan_date %q, returning it as-is", id, raw)
+	return raw
+}
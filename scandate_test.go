@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestScanDateSQL checks scanDateSQL reads each scan_date storage the way
+// parseScanDate does.
+func TestScanDateSQL(t *testing.T) {
+	openTestDB(t)
+	want := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		typ, value string
+		want       time.Time
+	}{
+		{"timestamptz", "2024-03-01T16:30:00+04:00", want},
+		{"timestamp", "2024-03-01 12:30:00", want},
+		{"date", "2024-03-01", want.Truncate(24 * time.Hour)},
+		{"text", "2024-03-01T12:30:00Z", want},
+		{"text", "2024-03-01 12:30:00", want},
+		{"text", "2024-03-01", want.Truncate(24 * time.Hour)},
+		{"bigint", "1709296200", want},
+		{"bigint", "1709296200000", want},
+		{"text", "1709296200", want},
+	} {
+		var got *time.Time
+		err := db().QueryRowContext(context.Background(),
+			`SELECT `+scanDateSQL+` FROM (SELECT $1::`+tc.typ+` AS scan_date) s`, tc.value).Scan(&got)
+		if err != nil {
+			t.Fatalf("%s %q: %v", tc.typ, tc.value, err)
+		}
+		if got == nil || !got.Equal(tc.want) {
+			t.Errorf("%s %q = %v, want %v", tc.typ, tc.value, got, tc.want)
+		}
+		if p, ok := parseScanDateString(tc.value); !ok || !p.Equal(tc.want) {
+			t.Errorf("parseScanDate(%q) = %v, want %v", tc.value, p, tc.want)
+		}
+	}
+
+	var got *time.Time
+	if err := db().QueryRowContext(context.Background(),
+		`SELECT `+scanDateSQL+` FROM (SELECT 'pending'::text AS scan_date) s`).Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("unparseable text = %v, want NULL", got)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Columns that /api/packets can filter or order by, keyed by JSON name.
+var (
+	filterableFields = map[string]bool{
+		"id": true, "checked_at": true, "header_checksum": true, "protocol": true,
+		"source_ip": true, "destination_ip": true, "source_sensor": true,
+	}
+	sortableFields = map[string]bool{"id": true}
+)
+
+type schemaField struct {
+	Name       string `json:"name"`
+	JSONKey    string `json:"json_key"`
+	Type       string `json:"type"`
+	Filterable bool   `json:"filterable"`
+	Sortable   bool   `json:"sortable"`
+}
+
+func schemaType(t reflect.Type) string {
+	if t == reflect.TypeFor[time.Time]() {
+		return "timestamp"
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Pointer:
+		return schemaType(t.Elem())
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return t.Kind().String()
+}
+
+// packetSchema describes the JSON shape of PacketInfo.
+func packetSchema() []schemaField {
+	t := reflect.TypeFor[PacketInfo]()
+	fields := make([]schemaField, 0, t.NumField())
+	for i := range t.NumField() {
+		f := t.Field(i)
+		key, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if key == "-" || !f.IsExported() {
+			continue
+		}
+		if key == "" {
+			key = f.Name
+		}
+		jsonKey := key
+		if jsonCamelCase {
+			// Match the keys writeJSON actually sends.
+			jsonKey = snakeToCamel(key)
+		}
+		fields = append(fields, schemaField{
+			Name:       f.Name,
+			JSONKey:    jsonKey,
+			Type:       schemaType(f.Type),
+			Filterable: filterableFields[key],
+			Sortable:   sortableFields[key],
+		})
+	}
+	return fields
+}
+
+// buildVersion identifies the running build: the module version and VCS
+// revision stamped in by go build.
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	version := info.Main.Version
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" || s.Key == "vcs.modified" {
+			version += " " + s.Key + "=" + s.Value
+		}
+	}
+	return version
+}
+
+// schemaETag tags the schema response. It only changes between
+// deployments, so it is computed once per process from the build and the
+// schema itself (covering builds without VCS stamps).
+var schemaETag = sync.OnceValues(func() (string, error) {
+	return dataETag(struct {
+		Build     string
+		CamelCase bool
+		Fields    []schemaField
+	}{buildVersion(), jsonCamelCase, packetSchema()})
+})
+
+func handleSchemaAPI(w http.ResponseWriter, r *http.Request) {
+	etag, err := schemaETag()
+	if err != nil {
+		log.Printf("Schema ETag error: %v", err)
+	} else {
+		// Pretty and compact bodies differ byte for byte, so they get
+		// different strong tags; Accept can choose between them.
+		if wantsPrettyJSON(r) {
+			etag = strings.TrimSuffix(etag, `"`) + `-pretty"`
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Add("Vary", "Accept")
+		if etagMatches(r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	writeJSON(w, r, map[string]any{"fields": packetSchema()})
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestPacketSchemaJSONCase(t *testing.T) {
+	defer func() { jsonCamelCase = false }()
+	for _, tc := range []struct {
+		camel bool
+		want  string
+	}{
+		{false, "source_ip"},
+		{true, "sourceIp"},
+	} {
+		jsonCamelCase = tc.camel
+		var found bool
+		for _, f := range packetSchema() {
+			if f.Name == "SourceIP" {
+				found = true
+				if f.JSONKey != tc.want {
+					t.Errorf("camel=%v: json_key = %q, want %q", tc.camel, f.JSONKey, tc.want)
+				}
+				if !f.Filterable {
+					t.Errorf("camel=%v: source_ip not filterable", tc.camel)
+				}
+			}
+		}
+		if !found {
+			t.Fatal("SourceIP missing from schema")
+		}
+	}
+}
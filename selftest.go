@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// startTime is when the process started, for uptime reporting.
+var startTime = time.Now()
+
+type selfTestCheck struct {
+	OK         bool    `json:"ok"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+type selfTestReport struct {
+	Status    string                   `json:"status"`
+	Checks    map[string]selfTestCheck `json:"checks"`
+	RowCount  *int64                   `json:"row_count,omitempty"`
+	Pool      selfTestPool             `json:"pool"`
+	Memory    selfTestMemory           `json:"memory"`
+	Uptime    string                   `json:"uptime"`
+	GoVersion string                   `json:"go_version"`
+	InFlight  int64                    `json:"in_flight"`
+}
+
+type selfTestPool struct {
+	MaxOpen      int    `json:"max_open"`
+	Open         int    `json:"open"`
+	InUse        int    `json:"in_use"`
+	Idle         int    `json:"idle"`
+	WaitCount    int64  `json:"wait_count"`
+	WaitDuration string `json:"wait_duration"`
+}
+
+type selfTestMemory struct {
+	AllocBytes uint64 `json:"alloc_bytes"`
+	SysBytes   uint64 `json:"sys_bytes"`
+	HeapObjs   uint64 `json:"heap_objects"`
+	NumGC      uint32 `json:"num_gc"`
+	Goroutines int    `json:"goroutines"`
+}
+
+func runCheck(fn func() error) selfTestCheck {
+	start := time.Now()
+	err := fn()
+	c := selfTestCheck{OK: err == nil, DurationMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	return c
+}
+
+// handleSelfTest gathers what field support usually asks for in one call:
+// database reachability, a trivial query, the packet count, connection pool
+// stats and runtime memory.
+func handleSelfTest(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	report := selfTestReport{
+		Status:    "ok",
+		Checks:    map[string]selfTestCheck{},
+		Uptime:    time.Since(startTime).Round(time.Second).String(),
+		GoVersion: runtime.Version(),
+		InFlight:  inFlight.Load(),
+	}
+
+	report.Checks["ping"] = runCheck(func() error { return db().PingContext(ctx) })
+	report.Checks["query"] = runCheck(func() error {
+		var one int
+		return db().QueryRowContext(ctx, tagQuery("SELECT 1")).Scan(&one)
+	})
+	report.Checks["row_count"] = runCheck(func() error {
+		var n int64
+		if err := db().QueryRowContext(ctx, tagQuery("SELECT COUNT(*) FROM packet_info")).Scan(&n); err != nil {
+			return err
+		}
+		report.RowCount = &n
+		return nil
+	})
+	for _, c := range report.Checks {
+		if !c.OK {
+			report.Status = "degraded"
+		}
+	}
+
+	stats := db().Stats()
+	report.Pool = selfTestPool{
+		MaxOpen:      stats.MaxOpenConnections,
+		Open:         stats.OpenConnections,
+		InUse:        stats.InUse,
+		Idle:         stats.Idle,
+		WaitCount:    stats.WaitCount,
+		WaitDuration: stats.WaitDuration.String(),
+	}
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	report.Memory = selfTestMemory{
+		AllocBytes: m.Alloc,
+		SysBytes:   m.Sys,
+		HeapObjs:   m.HeapObjects,
+		NumGC:      m.NumGC,
+		Goroutines: runtime.NumGoroutine(),
+	}
+
+	writeJSON(w, r, report)
+}
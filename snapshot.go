@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	snapshotWidth     = 800
+	snapshotRowHeight = 28
+	snapshotMargin    = 20
+	snapshotLabelCols = 160
+	snapshotMaxRows   = 20
+)
+
+var (
+	snapshotBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	snapshotText       = color.RGBA{0x21, 0x25, 0x29, 0xff}
+	snapshotBar        = color.RGBA{0x0d, 0x6e, 0xfd, 0xff}
+)
+
+type protocolRow struct {
+	Protocol string
+	protocolStats
+}
+
+// renderSnapshot draws a horizontal bar chart of packets per protocol. It is
+// rendered server-side with the built-in bitmap font so no browser or system
+// fonts are needed.
+func renderSnapshot(stats trafficStats, title string, at time.Time) image.Image {
+	protocols := make([]protocolRow, 0, len(stats.Protocols))
+	for name, p := range stats.Protocols {
+		protocols = append(protocols, protocolRow{name, p})
+	}
+	slices.SortFunc(protocols, func(a, b protocolRow) int {
+		return cmp.Or(cmp.Compare(b.Packets, a.Packets), cmp.Compare(a.Protocol, b.Protocol))
+	})
+	if len(protocols) > snapshotMaxRows {
+		protocols = protocols[:snapshotMaxRows]
+	}
+
+	header := 3 * snapshotRowHeight
+	height := header + max(len(protocols), 1)*snapshotRowHeight + snapshotMargin
+	img := image.NewRGBA(image.Rect(0, 0, snapshotWidth, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(snapshotBackground), image.Point{}, draw.Src)
+
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(snapshotText), Face: basicfont.Face7x13}
+	text := func(x, y int, s string) {
+		d.Dot = fixed.P(x, y)
+		d.DrawString(s)
+	}
+
+	text(snapshotMargin, snapshotMargin+13, title)
+	text(snapshotMargin, snapshotMargin+13+snapshotRowHeight/2+6,
+		fmt.Sprintf("%s  -  %d packets, %d bytes", at.UTC().Format(time.RFC3339), stats.TotalPackets, stats.TotalBytes))
+
+	if len(protocols) == 0 {
+		text(snapshotMargin, header+snapshotRowHeight/2, "No packets")
+		return img
+	}
+
+	barX := snapshotMargin + snapshotLabelCols
+	barMax := snapshotWidth - barX - snapshotMargin - 100
+	peak := protocols[0].Packets
+	for i, p := range protocols {
+		y := header + i*snapshotRowHeight
+		text(snapshotMargin, y+snapshotRowHeight/2+4, p.Protocol)
+
+		w := 0
+		if peak > 0 {
+			w = max(p.Packets*barMax/peak, 1)
+		}
+		bar := image.Rect(barX, y+4, barX+w, y+snapshotRowHeight-4)
+		draw.Draw(img, bar, image.NewUniform(snapshotBar), image.Point{}, draw.Src)
+		text(barX+w+6, y+snapshotRowHeight/2+4, fmt.Sprintf("%d", p.Packets))
+	}
+
+	return img
+}
+
+// handleSnapshot serves a PNG chart of the current traffic stats for
+// attaching to incident reports. ?window= restricts it the same way as
+// /api/stats.
+func handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	window, err := optionalWindowParam(r, "window")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var since *time.Time
+	title := "Packets by protocol (all time)"
+	if window > 0 {
+		t := time.Now().Add(-window)
+		since = &t
+		title = "Packets by protocol (last " + window.String() + ")"
+	}
+
+	stats, err := getTrafficStats(ctx, since, 0)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	now := time.Now()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, renderSnapshot(stats, title, now)); err != nil {
+		http.Error(w, "Error rendering snapshot", http.StatusInternalServerError)
+		log.Printf("Snapshot error: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="snapshot-%s.png"`, now.UTC().Format("20060102T150405Z")))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxSparklineBuckets = 200
+
+// getProtocolBuckets counts packets of one protocol in n equal buckets
+// covering [from, from+n*width).
+func getProtocolBuckets(ctx context.Context, protocol string, from time.Time, width time.Duration, n int) ([]int, error) {
+	query := `
+		SELECT FLOOR(EXTRACT(EPOCH FROM (checked_at - $1)) / $2)::int AS bucket, COUNT(*)
+		FROM packet_info
+		WHERE protocol = $3 AND checked_at >= $1 AND checked_at < $4
+		GROUP BY bucket
+	`
+
+	to := from.Add(width * time.Duration(n))
+	rows, err := db().QueryContext(ctx, tagQuery(query), from, width.Seconds(), protocol, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make([]int, n)
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		if bucket >= 0 && bucket < n {
+			counts[bucket] = count
+		}
+	}
+
+	return counts, rows.Err()
+}
+
+func handleSparklineAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	protocol := r.URL.Query().Get("protocol")
+	if protocol == "" {
+		http.Error(w, "protocol is required", http.StatusBadRequest)
+		return
+	}
+
+	buckets := 20
+	if s := r.URL.Query().Get("buckets"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxSparklineBuckets {
+			http.Error(w, "Invalid buckets", http.StatusBadRequest)
+			return
+		}
+		buckets = n
+	}
+
+	window, err := windowParam(r, "window", time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	width := window / time.Duration(buckets)
+	if width <= 0 {
+		http.Error(w, "window too small for bucket count", http.StatusBadRequest)
+		return
+	}
+
+	counts, err := getProtocolBuckets(ctx, protocol, time.Now().Add(-width*time.Duration(buckets)), width, buckets)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	writeJSON(w, r, counts)
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The admin SQL explorer runs ad-hoc SELECTs on adminSQLDB: a separate
+// connection (ADMIN_SQL_DB_URL, ideally a read-only role) or, when nil, the
+// main pool. Every query also runs in a READ ONLY transaction with a
+// statement timeout.
+var (
+	adminSQLDB      *sql.DB
+	adminSQLTimeout = 5 * time.Second
+	adminSQLMaxRows = 1000
+)
+
+// sqlDeniedWords may not appear anywhere in an explorer query outside
+// string literals, quoted identifiers and comments. Most are already
+// rejected by the READ ONLY transaction; the functions are not.
+var sqlDeniedWords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "INTO": true,
+	"TRUNCATE": true, "DROP": true, "ALTER": true, "CREATE": true, "GRANT": true,
+	"REVOKE": true, "COPY": true, "CALL": true, "DO": true, "LOCK": true,
+	"VACUUM": true, "LISTEN": true, "NOTIFY": true, "SET": true, "RESET": true,
+	"SET_CONFIG": true, "PG_TERMINATE_BACKEND": true, "PG_CANCEL_BACKEND": true,
+	"PG_RELOAD_CONF": true, "PG_READ_FILE": true, "PG_READ_BINARY_FILE": true,
+	"PG_LS_DIR": true, "LO_IMPORT": true, "LO_EXPORT": true, "DBLINK": true,
+	"DBLINK_EXEC": true, "PG_ADVISORY_LOCK": true, "PG_ADVISORY_XACT_LOCK": true,
+}
+
+type sqlToken struct {
+	word  string // upper-cased keyword or bare identifier; empty otherwise
+	punct byte
+}
+
+// tokenizeSQL splits a query into bare words and punctuation, skipping
+// whitespace, comments, string literals (including E'...' and dollar-quoted
+// strings) and quoted identifiers.
+func tokenizeSQL(q string) ([]sqlToken, error) {
+	var tokens []sqlToken
+	isWordStart := func(c byte) bool { return c == '_' || unicode.IsLetter(rune(c)) || c >= 0x80 }
+	isWordPart := func(c byte) bool { return isWordStart(c) || c == '$' || (c >= '0' && c <= '9') }
+
+	for i := 0; i < len(q); {
+		c := q[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case strings.HasPrefix(q[i:], "--"):
+			end := strings.IndexByte(q[i:], '\n')
+			if end < 0 {
+				i = len(q)
+			} else {
+				i += end + 1
+			}
+		case strings.HasPrefix(q[i:], "/*"):
+			depth := 0
+			for {
+				if i >= len(q) {
+					return nil, fmt.Errorf("unterminated comment")
+				}
+				if strings.HasPrefix(q[i:], "/*") {
+					depth++
+					i += 2
+				} else if strings.HasPrefix(q[i:], "*/") {
+					depth--
+					i += 2
+					if depth == 0 {
+						break
+					}
+				} else {
+					i++
+				}
+			}
+		case c == '\'' || ((c == 'E' || c == 'e') && i+1 < len(q) && q[i+1] == '\''):
+			backslash := c != '\''
+			if backslash {
+				i++
+			}
+			i++
+			for {
+				if i >= len(q) {
+					return nil, fmt.Errorf("unterminated string literal")
+				}
+				if backslash && q[i] == '\\' {
+					i += 2
+					continue
+				}
+				if q[i] == '\'' {
+					if i+1 < len(q) && q[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				i++
+			}
+		case c == '"':
+			i++
+			for {
+				if i >= len(q) {
+					return nil, fmt.Errorf("unterminated quoted identifier")
+				}
+				if q[i] == '"' {
+					if i+1 < len(q) && q[i+1] == '"' {
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				i++
+			}
+		case c == '$' && i+1 < len(q) && (q[i+1] == '$' || isWordStart(q[i+1])):
+			j := i + 1
+			for j < len(q) && q[j] != '$' && isWordPart(q[j]) {
+				j++
+			}
+			if j >= len(q) || q[j] != '$' {
+				return nil, fmt.Errorf("invalid dollar quote")
+			}
+			tag := q[i : j+1]
+			end := strings.Index(q[j+1:], tag)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated dollar-quoted string")
+			}
+			i = j + 1 + end + len(tag)
+		case isWordStart(c):
+			j := i
+			for j < len(q) && isWordPart(q[j]) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{word: strings.ToUpper(q[i:j])})
+			i = j
+		case c >= '0' && c <= '9':
+			for i < len(q) && (isWordPart(q[i]) || q[i] == '.') {
+				i++
+			}
+		default:
+			tokens = append(tokens, sqlToken{punct: c})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// checkReadOnlySQL accepts a single SELECT (or WITH ... SELECT) statement.
+func checkReadOnlySQL(q string) error {
+	tokens, err := tokenizeSQL(q)
+	if err != nil {
+		return err
+	}
+	for len(tokens) > 0 && tokens[len(tokens)-1].punct == ';' {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) == 0 {
+		return fmt.Errorf("empty query")
+	}
+	if first := tokens[0].word; first != "SELECT" && first != "WITH" {
+		return fmt.Errorf("only SELECT queries are allowed")
+	}
+	for _, t := range tokens {
+		if t.punct == ';' {
+			return fmt.Errorf("only a single statement is allowed")
+		}
+		if sqlDeniedWords[t.word] {
+			return fmt.Errorf("%s is not allowed in explorer queries", t.word)
+		}
+	}
+	return nil
+}
+
+type sqlResult struct {
+	Columns    []string `json:"columns"`
+	Rows       [][]any  `json:"rows"`
+	Truncated  bool     `json:"truncated"`
+	DurationMS float64  `json:"duration_ms"`
+}
+
+func runAdminSQL(ctx context.Context, q string) (sqlResult, error) {
+	res := sqlResult{Rows: [][]any{}}
+	start := time.Now()
+
+	sqlDB := adminSQLDB
+	if sqlDB == nil {
+		sqlDB = db()
+	}
+	tx, err := sqlDB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback()
+
+	timeout := fmt.Sprintf("SET LOCAL statement_timeout = %d", adminSQLTimeout.Milliseconds())
+	if _, err := tx.ExecContext(ctx, timeout); err != nil {
+		return res, err
+	}
+
+	rows, err := tx.QueryContext(ctx, tagQuery(q))
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+
+	if res.Columns, err = rows.Columns(); err != nil {
+		return res, err
+	}
+	for rows.Next() {
+		if len(res.Rows) == adminSQLMaxRows {
+			res.Truncated = true
+			break
+		}
+		values := make([]any, len(res.Columns))
+		dest := make([]any, len(values))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return res, err
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		res.Rows = append(res.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return res, err
+	}
+
+	res.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+	return res, nil
+}
+
+// handleAdminSQL serves the explorer page on GET and runs a query on POST.
+// The page holds no data and asks for the admin token itself, so only the
+// POST requires it.
+func handleAdminSQL(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if adminToken == "" {
+			http.Error(w, "Admin endpoints are disabled (ADMIN_TOKEN not set)", http.StatusForbidden)
+			return
+		}
+		page, err := templateFS.ReadFile("templates/sql.html")
+		if err != nil {
+			http.Error(w, "Error loading page", http.StatusInternalServerError)
+			log.Printf("SQL explorer page error: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	case http.MethodPost:
+		requireAdmin(handleAdminSQLQuery)(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleAdminSQLQuery(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query string `json:"query"`
+	}
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if err := checkReadOnlySQL(req.Query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), adminSQLTimeout+time.Second)
+	defer cancel()
+
+	res, err := runAdminSQL(ctx, req.Query)
+	if err != nil {
+		// The query came from an admin, so the database error is the
+		// useful answer.
+		http.Error(w, "Query failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Admin SQL from %s: %d rows in %.1fms", clientIP(r), len(res.Rows), res.DurationMS)
+
+	w.Header().Set("X-Row-Limit", strconv.Itoa(adminSQLMaxRows))
+	writeJSON(w, r, res)
+}
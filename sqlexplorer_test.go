@@ -0,0 +1,83 @@
+package main
+
+import "testing"
+
+func TestCheckReadOnlySQL(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		ok    bool
+	}{
+		{`SELECT 1`, true},
+		{`select * from packet_info;`, true},
+		{`SELECT 1;;`, true},
+		{`WITH recent AS (SELECT * FROM packet_info) SELECT count(*) FROM recent`, true},
+
+		// Comments, strings and quoted identifiers hide what they contain.
+		{`SELECT 1 -- ; DROP TABLE packet_info`, true},
+		{`SELECT 1 /* ; DELETE FROM packet_info */`, true},
+		{`SELECT 1 /* outer /* inner ; */ still a comment ; DROP */`, true},
+		{`SELECT protocol FROM packet_info WHERE protocol = 'DELETE; DROP TABLE x'`, true},
+		{`SELECT 'it''s; DELETE'`, true},
+		{`SELECT E'\'; DROP TABLE packet_info; --'`, true},
+		{`SELECT $$; DROP TABLE packet_info$$`, true},
+		{`SELECT $tag$ $$ ; DELETE FROM t $$ $tag$`, true},
+		{`SELECT "delete", "Drop" FROM packet_info`, true},
+		{`SELECT "a""; DELETE" FROM t`, true},
+
+		// ... but only what they contain.
+		{`SELECT 1 -- comment` + "\n" + `; DROP TABLE packet_info`, false},
+		{`SELECT 1 /* comment */ ; DELETE FROM packet_info`, false},
+		{`SELECT 'a\'; DELETE FROM packet_info; --'`, false}, // no backslash escapes outside E''
+		{`SELECT E'\'' ; DELETE FROM packet_info`, false},
+		{`SELECT 'it'''; DELETE FROM packet_info`, false},
+		{`SELECT $tag$ x $tag$; DROP TABLE packet_info`, false},
+		{`SELECT "x"; DROP TABLE packet_info`, false},
+
+		// Multiple statements and writes.
+		{`SELECT 1; SELECT 2`, false},
+		{`SELECT 1; DROP TABLE packet_info`, false},
+		{`sElEcT 1; dElEtE FROM packet_info`, false},
+		{`WITH d AS (DELETE FROM packet_info RETURNING *) SELECT * FROM d`, false},
+		{`WITH u AS (UPDATE packet_info SET ttl = 0 RETURNING id) SELECT * FROM u`, false},
+		{`SELECT * INTO copy FROM packet_info`, false},
+		{`SELECT set_config('default_transaction_read_only', 'off', false)`, false},
+		{`SELECT pg_terminate_backend(1)`, false},
+		{`DELETE FROM packet_info`, false},
+		{`EXPLAIN ANALYZE DELETE FROM packet_info`, false},
+		{`TABLE packet_info`, false},
+
+		// Malformed input.
+		{``, false},
+		{`;`, false},
+		{`SELECT 'unterminated`, false},
+		{`SELECT E'\'`, false},
+		{`SELECT "unterminated`, false},
+		{`SELECT 1 /* unterminated`, false},
+		{`SELECT 1 /* /* */`, false},
+		{`SELECT $$unterminated`, false},
+		{`SELECT $tag$ x $other$`, false},
+	} {
+		err := checkReadOnlySQL(tc.query)
+		if (err == nil) != tc.ok {
+			t.Errorf("checkReadOnlySQL(%q) = %v, want ok %v", tc.query, err, tc.ok)
+		}
+	}
+}
+
+func TestTokenizeSQL(t *testing.T) {
+	tokens, err := tokenizeSQL(`SELECT "Drop", E'x\'y', $q$;$q$ /* c */ FROM t -- x` + "\n" + `WHERE id = 1.5;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	for _, tok := range tokens {
+		if tok.word != "" {
+			got += tok.word + " "
+		} else {
+			got += string(tok.punct) + " "
+		}
+	}
+	if want := "SELECT , , FROM T WHERE ID = ; "; got != want {
+		t.Errorf("tokens = %q, want %q", got, want)
+	}
+}
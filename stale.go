@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Degraded mode (STALE_TTL): the last successful response of each read
+// endpoint is kept for staleTTL. When a later request fails with a 5xx, or
+// its queries take longer than staleThreshold while a copy exists, the copy
+// is served instead with X-Served-Stale: true.
+var (
+	staleTTL       time.Duration
+	staleThreshold time.Duration
+)
+
+// maxStaleEntries bounds the number of distinct URLs kept.
+const maxStaleEntries = 256
+
+var staleRoutes = append([]string{"/api/packets"}, cacheableRoutes...)
+
+type staleEntry struct {
+	header http.Header
+	body   []byte
+	stored time.Time
+}
+
+var (
+	staleMu    sync.Mutex
+	staleCache = map[string]staleEntry{}
+)
+
+func lookupStale(key string) (staleEntry, bool) {
+	staleMu.Lock()
+	defer staleMu.Unlock()
+	e, ok := staleCache[key]
+	ok = ok && time.Since(e.stored) <= staleTTL
+	staleCounters.record(ok)
+	if !ok {
+		return staleEntry{}, false
+	}
+	return e, true
+}
+
+func storeStale(key string, e staleEntry) {
+	staleMu.Lock()
+	defer staleMu.Unlock()
+	if _, ok := staleCache[key]; !ok && len(staleCache) >= maxStaleEntries {
+		oldest := slices.MinFunc(slices.Collect(maps.Keys(staleCache)), func(a, b string) int {
+			return staleCache[a].stored.Compare(staleCache[b].stored)
+		})
+		delete(staleCache, oldest)
+	}
+	staleCache[key] = e
+}
+
+// clearStale drops every kept response, for when deleted data must not be
+// served again (by-ip erasure, raw purges).
+func clearStale() {
+	staleMu.Lock()
+	defer staleMu.Unlock()
+	clear(staleCache)
+}
+
+// bufferedResponse holds a handler's response until staleFallback decides
+// whether to send it.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	if b.status == 0 {
+		b.status = code
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+func staleFallback(next http.Handler) http.Handler {
+	if staleTTL <= 0 {
+		return next
+	}
+	routes := map[string]bool{}
+	for _, route := range staleRoutes {
+		routes[route] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || !routes[unversionedPath(r.URL.Path)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.URL.RequestURI() + "\x00" + r.Header.Get("Accept")
+		cached, haveCached := lookupStale(key)
+		inner := r
+		if haveCached && r.Header.Get("X-Query-Timeout") == "" {
+			// With a fallback at hand, don't wait out the full query
+			// timeout, unless the client asked for a longer one.
+			ctx, cancel := context.WithTimeout(r.Context(), staleThreshold)
+			defer cancel()
+			inner = r.WithContext(ctx)
+		}
+
+		buf := &bufferedResponse{header: http.Header{}}
+		next.ServeHTTP(buf, inner)
+		// The mux records the matched route on the copy; outer layers
+		// (trackLatency) read it from r.
+		r.Pattern = inner.Pattern
+		if buf.status == 0 {
+			buf.status = http.StatusOK
+		}
+
+		if buf.status >= 500 && haveCached {
+			maps.Copy(w.Header(), cached.header)
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("X-Served-Stale", "true")
+			w.Header().Set("Age", strconv.Itoa(int(time.Since(cached.stored).Seconds())))
+			w.Write(cached.body)
+			return
+		}
+
+		maps.Copy(w.Header(), buf.header)
+		w.WriteHeader(buf.status)
+		w.Write(buf.body.Bytes())
+
+		if buf.status == http.StatusOK {
+			storeStale(key, staleEntry{header: buf.header.Clone(), body: buf.body.Bytes(), stored: time.Now()})
+		}
+	})
+}
@@ -0,0 +1,677 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Row limits for aggregate endpoints (STATS_DEFAULT_LIMIT, STATS_MAX_LIMIT).
+// Every ORDER BY ends in a unique key (the group key, or id for raw rows)
+// so that equal sort values come back in the same order on every request.
+var (
+	statsDefaultLimit = 100
+	statsMaxLimit     = 1000
+)
+
+// statsLimit reads ?limit= for an aggregate endpoint, capped at statsMaxLimit.
+func statsLimit(r *http.Request) (int, error) {
+	s := r.URL.Query().Get("limit")
+	if s == "" {
+		return statsDefaultLimit, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid limit %q", s)
+	}
+	return min(n, statsMaxLimit), nil
+}
+
+type checksumCount struct {
+	HeaderChecksum int `json:"header_checksum"`
+	Count          int `json:"count"`
+	Flows          int `json:"flows"`
+}
+
+// getRepeatedChecksums lists header checksums seen more than threshold times,
+// with the number of distinct source/destination pairs that carried them.
+func getRepeatedChecksums(ctx context.Context, threshold int, limit int) ([]checksumCount, error) {
+	query := `
+		SELECT header_checksum, COUNT(*) AS cnt, COUNT(DISTINCT (source_ip, destination_ip))
+		FROM packet_info
+		GROUP BY header_checksum
+		HAVING COUNT(*) > $1
+		ORDER BY cnt DESC, header_checksum
+		LIMIT $2
+	`
+
+	rows, err := db().QueryContext(ctx, tagQuery(query), threshold, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []checksumCount{}
+	for rows.Next() {
+		var c checksumCount
+		if err := rows.Scan(&c.HeaderChecksum, &c.Count, &c.Flows); err != nil {
+			return nil, err
+		}
+		results = append(results, c)
+	}
+
+	return results, rows.Err()
+}
+
+func handleChecksumStatsAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	if err := checkFullScan(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	threshold := 1
+	if s := r.URL.Query().Get("threshold"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid threshold", http.StatusBadRequest)
+			return
+		}
+		threshold = n
+	}
+
+	limit, err := statsLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := getRepeatedChecksums(ctx, threshold, limit)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	writeJSON(w, r, results)
+}
+
+// Per-source thresholds for /api/stats/connection-rate.
+var (
+	connRateMaxDestinations int
+	connRateMaxPackets      int
+)
+
+type connectionRate struct {
+	SourceIP            string `json:"source_ip"`
+	Destinations        int    `json:"destinations"`
+	Packets             int    `json:"packets"`
+	ExceedsDestinations bool   `json:"exceeds_destinations"`
+	ExceedsPackets      bool   `json:"exceeds_packets"`
+}
+
+// getConnectionRates returns sources that, within the window, contacted more
+// than maxDest distinct destinations or sent more than maxPackets packets.
+func getConnectionRates(ctx context.Context, since time.Time, maxDest, maxPackets int, limit int) ([]connectionRate, error) {
+	query := `
+		SELECT source_ip, COUNT(DISTINCT destination_ip) AS dests, COUNT(*) AS pkts
+		FROM packet_info
+		WHERE checked_at >= $1
+		GROUP BY source_ip
+		HAVING COUNT(DISTINCT destination_ip) > $2 OR COUNT(*) > $3
+		ORDER BY dests DESC, pkts DESC, source_ip
+		LIMIT $4
+	`
+
+	rows, err := db().QueryContext(ctx, tagQuery(query), since, maxDest, maxPackets, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []connectionRate{}
+	for rows.Next() {
+		var c connectionRate
+		if err := rows.Scan(&c.SourceIP, &c.Destinations, &c.Packets); err != nil {
+			return nil, err
+		}
+		c.ExceedsDestinations = c.Destinations > maxDest
+		c.ExceedsPackets = c.Packets > maxPackets
+		results = append(results, c)
+	}
+
+	return results, rows.Err()
+}
+
+func handleConnectionRateAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	window, err := windowParam(r, "window", 5*time.Minute)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := statsLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := getConnectionRates(ctx, time.Now().Add(-window), connRateMaxDestinations, connRateMaxPackets, limit)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	writeJSON(w, r, map[string]any{
+		"window":           window.String(),
+		"max_destinations": connRateMaxDestinations,
+		"max_packets":      connRateMaxPackets,
+		"sources":          results,
+	})
+}
+
+type targetCount struct {
+	DestinationIP string `json:"destination_ip"`
+	Packets       int    `json:"packets"`
+	Bytes         int64  `json:"bytes"`
+}
+
+// getTopTargets returns the destinations that received the most packets
+// checked at or after since.
+func getTopTargets(ctx context.Context, since time.Time, limit int) ([]targetCount, error) {
+	query := `
+		SELECT destination_ip, COUNT(*) AS cnt, COALESCE(SUM(` + clampedTotalLengthSQL + `), 0)
+		FROM packet_info
+		WHERE checked_at >= $1
+		GROUP BY destination_ip
+		ORDER BY cnt DESC, destination_ip
+		LIMIT $2
+	`
+
+	rows, err := db().QueryContext(ctx, tagQuery(query), since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []targetCount{}
+	for rows.Next() {
+		var t targetCount
+		if err := rows.Scan(&t.DestinationIP, &t.Packets, &t.Bytes); err != nil {
+			return nil, err
+		}
+		results = append(results, t)
+	}
+
+	return results, rows.Err()
+}
+
+func handleTopTargetsAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	window, err := windowParam(r, "window", 24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := statsLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := getTopTargets(ctx, time.Now().Add(-window), limit)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	writeJSON(w, r, map[string]any{
+		"window":  window.String(),
+		"targets": results,
+	})
+}
+
+type ttlCount struct {
+	TTL   int `json:"ttl"`
+	Count int `json:"count"`
+}
+
+type ttlGroupCount struct {
+	Group string `json:"group"`
+	Count int    `json:"count"`
+}
+
+// Initial TTLs commonly used by operating systems. An observed TTL is
+// attributed to the smallest default at or above it.
+var ttlOSDefaults = []struct {
+	max   int
+	group string
+}{
+	{64, "64 (Linux/Unix/macOS)"},
+	{128, "128 (Windows)"},
+	{255, "255 (network devices)"},
+}
+
+func getTTLCounts(ctx context.Context) ([]ttlCount, error) {
+	rows, err := db().QueryContext(ctx, tagQuery(`SELECT ttl, COUNT(*) FROM packet_info GROUP BY ttl ORDER BY ttl`))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []ttlCount{}
+	for rows.Next() {
+		var c ttlCount
+		if err := rows.Scan(&c.TTL, &c.Count); err != nil {
+			return nil, err
+		}
+		results = append(results, c)
+	}
+
+	return results, rows.Err()
+}
+
+func groupTTLsByOS(counts []ttlCount) []ttlGroupCount {
+	groups := make([]ttlGroupCount, len(ttlOSDefaults)+1)
+	for i, d := range ttlOSDefaults {
+		groups[i].Group = d.group
+	}
+	groups[len(ttlOSDefaults)].Group = "other"
+
+	for _, c := range counts {
+		idx := len(ttlOSDefaults)
+		for i, d := range ttlOSDefaults {
+			if c.TTL > 0 && c.TTL <= d.max {
+				idx = i
+				break
+			}
+		}
+		groups[idx].Count += c.Count
+	}
+	return groups
+}
+
+func handleTTLStatsAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	if err := checkFullScan(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	counts, err := getTTLCounts(ctx)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	if r.URL.Query().Get("group") == "os" {
+		writeJSON(w, r, groupTTLsByOS(counts))
+		return
+	}
+	writeJSON(w, r, counts)
+}
+
+type flagsCount struct {
+	Flags    string    `json:"flags"`
+	Count    int       `json:"count"`
+	TCPFlags *TCPFlags `json:"tcp_flags,omitempty"`
+}
+
+// getFlagsCounts counts packets per stored flags value, optionally for one
+// protocol. Packets without flags are reported as "none".
+func getFlagsCounts(ctx context.Context, protocol string, limit int) ([]flagsCount, error) {
+	query := `
+		SELECT flags, COUNT(*) AS cnt
+		FROM packet_info
+		WHERE $1 = '' OR protocol = $1
+		GROUP BY flags
+		ORDER BY cnt DESC, flags NULLS FIRST
+		LIMIT $2
+	`
+
+	rows, err := db().QueryContext(ctx, tagQuery(query), protocol, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []flagsCount{}
+	for rows.Next() {
+		var flags sql.NullString
+		var c flagsCount
+		if err := rows.Scan(&flags, &c.Count); err != nil {
+			return nil, err
+		}
+		c.Flags = "none"
+		if flags.Valid {
+			c.Flags = flags.String
+			if isTCP(protocol) {
+				c.TCPFlags = parseFlags(flags.String)
+			}
+		}
+		results = append(results, c)
+	}
+
+	return results, rows.Err()
+}
+
+func handleFlagsStatsAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	if err := checkFullScan(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := statsLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	counts, err := getFlagsCounts(ctx, r.URL.Query().Get("protocol"), limit)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	writeJSON(w, r, counts)
+}
+
+type protocolStats struct {
+	Packets int     `json:"packets"`
+	Bytes   int64   `json:"bytes"`
+	AvgSize float64 `json:"avg_size"`
+}
+
+type trafficStats struct {
+	Window       string                   `json:"window,omitempty"`
+	TotalPackets int                      `json:"total_packets"`
+	TotalBytes   int64                    `json:"total_bytes"`
+	Protocols    map[string]protocolStats `json:"protocols"`
+}
+
+// getTrafficStats aggregates packet and byte counts per protocol, optionally
+// restricted to packets checked at or after since. Only the limit busiest
+// protocols are listed (all of them for 0); the totals cover every one.
+func getTrafficStats(ctx context.Context, since *time.Time, limit int) (trafficStats, error) {
+	query := `
+		SELECT protocol, COUNT(*), COALESCE(SUM(` + clampedTotalLengthSQL + `), 0), COALESCE(AVG(` + clampedTotalLengthSQL + `), 0),
+		       SUM(COUNT(*)) OVER (), COALESCE(SUM(SUM(` + clampedTotalLengthSQL + `)) OVER (), 0)
+		FROM packet_info
+		WHERE $1::timestamptz IS NULL OR checked_at >= $1
+		GROUP BY protocol
+		ORDER BY COUNT(*) DESC, protocol
+		LIMIT NULLIF($2, 0)
+	`
+
+	stats := trafficStats{Protocols: map[string]protocolStats{}}
+	rows, err := db().QueryContext(ctx, tagQuery(query), since, limit)
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var protocol string
+		var p protocolStats
+		if err := rows.Scan(&protocol, &p.Packets, &p.Bytes, &p.AvgSize, &stats.TotalPackets, &stats.TotalBytes); err != nil {
+			return stats, err
+		}
+		stats.Protocols[protocol] = p
+	}
+
+	return stats, rows.Err()
+}
+
+func handleStatsAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	window, err := optionalWindowParam(r, "window")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var since *time.Time
+	if window > 0 {
+		t := time.Now().Add(-window)
+		since = &t
+	}
+
+	limit, err := statsLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stats, err := getTrafficStats(ctx, since, limit)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+	if since != nil {
+		stats.Window = window.String()
+	}
+
+	writeJSON(w, r, stats)
+}
+
+// sizeBuckets are the lower bounds of the total_length ranges used by
+// /api/stats/size-threat; each range runs up to the next bound.
+var sizeBuckets = []int{0, 64, 128, 256, 512, 1024, 1500}
+
+type sizeThreat struct {
+	MinSize       int      `json:"min_size"`
+	MaxSize       *int     `json:"max_size"` // exclusive; null for the last bucket
+	Packets       int      `json:"packets"`
+	Malicious     int      `json:"malicious"`
+	MaliciousRate *float64 `json:"malicious_rate"` // percent; null when empty
+}
+
+// getSizeThreat reports, per packet size range, how many packets were seen
+// and how many had at least one malicious verdict.
+func getSizeThreat(ctx context.Context, since *time.Time) ([]sizeThreat, error) {
+	const size = clampedTotalLengthSQL
+	var cols []string
+	for i, lo := range sizeBuckets {
+		cond := fmt.Sprintf("%s >= %d", size, lo)
+		if i+1 < len(sizeBuckets) {
+			cond += fmt.Sprintf(" AND %s < %d", size, sizeBuckets[i+1])
+		}
+		cols = append(cols,
+			fmt.Sprintf("COUNT(*) FILTER (WHERE %s)", cond),
+			fmt.Sprintf("COUNT(*) FILTER (WHERE %s AND malicious > 0)", cond))
+	}
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM packet_info
+		WHERE $1::timestamptz IS NULL OR checked_at >= $1
+	`, strings.Join(cols, ", "))
+
+	results := make([]sizeThreat, len(sizeBuckets))
+	dest := make([]any, 0, 2*len(sizeBuckets))
+	for i, lo := range sizeBuckets {
+		results[i].MinSize = lo
+		if i+1 < len(sizeBuckets) {
+			results[i].MaxSize = &sizeBuckets[i+1]
+		}
+		dest = append(dest, &results[i].Packets, &results[i].Malicious)
+	}
+	if err := db().QueryRowContext(ctx, tagQuery(query), since).Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		if results[i].Packets > 0 {
+			rate := 100 * float64(results[i].Malicious) / float64(results[i].Packets)
+			results[i].MaliciousRate = &rate
+		}
+	}
+	return results, nil
+}
+
+func handleSizeThreatAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	window, err := optionalWindowParam(r, "window")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var since *time.Time
+	if window > 0 {
+		t := time.Now().Add(-window)
+		since = &t
+	}
+
+	buckets, err := getSizeThreat(ctx, since)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	writeJSON(w, r, buckets)
+}
+
+type subnetCount struct {
+	Subnet  string `json:"subnet"`
+	Packets int    `json:"packets"`
+	Sources int    `json:"sources"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// getSubnetCounts groups source IPs into networks of the given prefix
+// length, mask4 for IPv4 and mask6 for IPv6 addresses.
+func getSubnetCounts(ctx context.Context, mask4, mask6 int, limit int) ([]subnetCount, error) {
+	query := `
+		SELECT network(set_masklen(source_ip::inet,
+		           CASE WHEN family(source_ip::inet) = 4 THEN $1 ELSE $2 END))::text AS subnet,
+		       COUNT(*) AS pkts,
+		       COUNT(DISTINCT source_ip),
+		       COALESCE(SUM(` + clampedTotalLengthSQL + `), 0)
+		FROM packet_info
+		GROUP BY subnet
+		ORDER BY pkts DESC, subnet
+		LIMIT $3
+	`
+
+	rows, err := db().QueryContext(ctx, tagQuery(query), mask4, mask6, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []subnetCount{}
+	for rows.Next() {
+		var c subnetCount
+		if err := rows.Scan(&c.Subnet, &c.Packets, &c.Sources, &c.Bytes); err != nil {
+			return nil, err
+		}
+		results = append(results, c)
+	}
+
+	return results, rows.Err()
+}
+
+func handleSubnetStatsAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	if err := checkFullScan(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	maskParam := func(key string, def, maxBits int) (int, bool) {
+		s := r.URL.Query().Get(key)
+		if s == "" {
+			return def, true
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > maxBits {
+			http.Error(w, fmt.Sprintf("Invalid %s (0-%d)", key, maxBits), http.StatusBadRequest)
+			return 0, false
+		}
+		return n, true
+	}
+
+	mask4, ok := maskParam("mask", 24, 32)
+	if !ok {
+		return
+	}
+	mask6, ok := maskParam("mask6", 64, 128)
+	if !ok {
+		return
+	}
+
+	limit, err := statsLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := getSubnetCounts(ctx, mask4, mask6, limit)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	writeJSON(w, r, results)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// stmtCacheSize bounds how many prepared statements the packet listing
+// keeps (STMT_CACHE_SIZE). Zero disables caching. Statements are keyed by
+// their full text, so each distinct filter shape gets its own entry.
+var stmtCacheSize int
+
+// cachedStmt is a cache entry. refs counts callers between acquire and
+// release; an evicted entry is closed once the last of them releases it.
+type cachedStmt struct {
+	stmt    *sql.Stmt
+	pool    *sql.DB // where stmt was prepared
+	refs    int
+	evicted bool
+}
+
+var stmtCache = struct {
+	sync.Mutex
+	stmts map[string]*cachedStmt
+	order []string // insertion order, oldest first
+}{stmts: map[string]*cachedStmt{}}
+
+// acquireStmt returns the cached statement for query, preparing it on first
+// use and evicting the oldest entry once the cache is full. The caller must
+// releaseStmt it once the query has started. database/sql re-prepares it
+// transparently on whichever connection runs it.
+func acquireStmt(ctx context.Context, query string) (*cachedStmt, error) {
+	stmtCache.Lock()
+	cs, ok := stmtCache.stmts[query]
+	stmtCounters.record(ok)
+	if ok {
+		cs.refs++
+		stmtCache.Unlock()
+		return cs, nil
+	}
+	stmtCache.Unlock()
+
+	// Prepare without the lock so a slow prepare doesn't stall every
+	// other listing query.
+	conn := db()
+	stmt, err := conn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var toClose []*sql.Stmt
+	stmtCache.Lock()
+	if db() != conn {
+		// The pool was replaced, and the cache flushed, while preparing:
+		// use the statement once without caching it.
+		stmtCache.Unlock()
+		return &cachedStmt{stmt: stmt, pool: conn, refs: 1, evicted: true}, nil
+	}
+	cs, ok = stmtCache.stmts[query]
+	if ok {
+		// Another caller prepared it meanwhile; use theirs.
+		toClose = append(toClose, stmt)
+	} else {
+		if len(stmtCache.order) >= stmtCacheSize {
+			oldest := stmtCache.order[0]
+			stmtCache.order = stmtCache.order[1:]
+			old := stmtCache.stmts[oldest]
+			delete(stmtCache.stmts, oldest)
+			old.evicted = true
+			if old.refs == 0 {
+				toClose = append(toClose, old.stmt)
+			}
+		}
+		cs = &cachedStmt{stmt: stmt, pool: conn}
+		stmtCache.stmts[query] = cs
+		stmtCache.order = append(stmtCache.order, query)
+	}
+	cs.refs++
+	stmtCache.Unlock()
+
+	for _, s := range toClose {
+		s.Close()
+	}
+	return cs, nil
+}
+
+// flushStmtCache evicts every statement. Ones in use are closed when
+// released.
+func flushStmtCache() {
+	var toClose []*sql.Stmt
+	stmtCache.Lock()
+	for _, cs := range stmtCache.stmts {
+		cs.evicted = true
+		if cs.refs == 0 {
+			toClose = append(toClose, cs.stmt)
+		}
+	}
+	clear(stmtCache.stmts)
+	stmtCache.order = nil
+	stmtCache.Unlock()
+
+	for _, s := range toClose {
+		s.Close()
+	}
+}
+
+// releaseStmt drops a reference taken by acquireStmt, closing the statement
+// if it was evicted in the meantime. Rows already returned by it stay
+// valid: database/sql defers the close until they are done.
+func releaseStmt(cs *cachedStmt) {
+	stmtCache.Lock()
+	cs.refs--
+	closeNow := cs.evicted && cs.refs == 0
+	stmtCache.Unlock()
+
+	if closeNow {
+		cs.stmt.Close()
+	}
+}
+
+// queryCached runs query on q through the statement cache, so Postgres
+// parses and plans it once rather than on every poll.
+func queryCached(ctx context.Context, q querier, query string, args ...any) (*sql.Rows, error) {
+	if stmtCacheSize <= 0 {
+		return q.QueryContext(ctx, query, args...)
+	}
+	cs, err := acquireStmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStmt(cs)
+
+	if tx, ok := q.(tunedTx); ok {
+		if tx.pool != cs.pool {
+			// The pool was replaced since tx began; database/sql refuses
+			// statements from another pool.
+			return tx.QueryContext(ctx, query, args...)
+		}
+		return tx.StmtContext(ctx, cs.stmt).QueryContext(ctx, args...)
+	}
+	return cs.stmt.QueryContext(ctx, args...)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+// BenchmarkSelectPackets compares the polling query with and without the
+// statement cache. Run with TEST_DB_URL set:
+//
+//	go test -run '^$' -bench SelectPackets
+func BenchmarkSelectPackets(b *testing.B) {
+	openTestDB(b)
+	ids := insertTestPackets(b, 2000)
+	after := ids[len(ids)-100]
+
+	for _, size := range []int{0, 16} {
+		b.Run(fmt.Sprintf("cache=%d", size), func(b *testing.B) {
+			prev := stmtCacheSize
+			stmtCacheSize = size
+			defer func() { stmtCacheSize = prev }()
+
+			ctx := context.Background()
+			for b.Loop() {
+				if _, err := getPackets(ctx, packetFilter{AfterID: after, Protocol: "TCP", Limit: 50}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestStmtCacheEviction runs more distinct queries than the cache holds
+// from many goroutines, so entries are evicted while others still use them.
+func TestStmtCacheEviction(t *testing.T) {
+	openTestDB(t)
+	insertTestPackets(t, 10)
+
+	prev := stmtCacheSize
+	stmtCacheSize = 2
+	defer func() { stmtCacheSize = prev }()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for g := range 16 {
+		wg.Go(func() {
+			for i := range 50 {
+				// Each combination of set fields is a distinct statement.
+				k := (g + i) % 8
+				f := packetFilter{Limit: 5}
+				if k&1 != 0 {
+					f.AfterID = 1
+				}
+				if k&2 != 0 {
+					f.Protocol = "TCP"
+				}
+				if k&4 != 0 {
+					checksum := 0
+					f.HeaderChecksum = &checksum
+				}
+				if _, err := getPackets(context.Background(), f); err != nil {
+					errs <- err
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// TestStmtCachePoolSwap starts a tuned transaction, replaces the pool and
+// then runs a cached query through the transaction: the statement cached on
+// the new pool must not be handed to the old pool's transaction.
+func TestStmtCachePoolSwap(t *testing.T) {
+	openTestDB(t)
+	prevSize := stmtCacheSize
+	stmtCacheSize = 4
+	defer func() { stmtCacheSize = prevSize }()
+
+	ctx := context.Background()
+	pool := db()
+	tx, err := pool.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	other, err := sql.Open("postgres", os.Getenv("TEST_DB_URL"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	dbConn.Store(other)
+	flushStmtCache()
+	defer func() {
+		flushStmtCache()
+		dbConn.Store(pool)
+	}()
+
+	rows, err := queryCached(ctx, tunedTx{tx, pool}, "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var n int
+	for rows.Next() {
+		if err := rows.Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("SELECT 1 = %d", n)
+	}
+}
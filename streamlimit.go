@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+)
+
+// maxStreamsPerIP bounds how many streaming responses (SSE) one client IP
+// may hold open at once (STREAM_MAX_PER_IP). maxStreamsTotal bounds them
+// across all clients (STREAM_MAX_TOTAL); zero means no total cap.
+var (
+	maxStreamsPerIP = 4
+	maxStreamsTotal int
+)
+
+var (
+	streamsMu    sync.Mutex
+	streams      = map[string]int{}
+	streamsTotal int
+)
+
+// acquireStream counts a new stream for ip. When a limit is reached it
+// returns the status and message to refuse it with instead.
+func acquireStream(ip string) (int, string) {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+	if maxStreamsTotal > 0 && streamsTotal >= maxStreamsTotal {
+		return http.StatusServiceUnavailable, "Too many live subscribers on this server, try again later"
+	}
+	if streams[ip] >= maxStreamsPerIP {
+		return http.StatusTooManyRequests, "Too many concurrent streams from this client"
+	}
+	streams[ip]++
+	streamsTotal++
+	return 0, ""
+}
+
+func releaseStream(ip string) {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+	streamsTotal--
+	if streams[ip]--; streams[ip] <= 0 {
+		delete(streams, ip)
+	}
+}
+
+// openStreams is the number of streams currently held open.
+func openStreams() int {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+	return streamsTotal
+}
+
+// limitStreams rejects a streaming request with 429 while its client
+// already holds maxStreamsPerIP streams, so one client cannot take every
+// long-lived connection, and with 503 while the server holds
+// maxStreamsTotal.
+func limitStreams(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+		if code, msg := acquireStream(ip); code != 0 {
+			http.Error(w, msg, code)
+			return
+		}
+		defer releaseStream(ip)
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"time"
+)
+
+// tailPackets prints packets to out as they arrive, polling every interval,
+// until ctx is cancelled. Only packets newer than the latest one at start
+// are printed.
+func tailPackets(ctx context.Context, out io.Writer, protocol string, interval time.Duration) error {
+	latest, err := getPackets(ctx, packetFilter{Protocol: protocol, Limit: 1})
+	if err != nil {
+		return err
+	}
+	lastID := 0
+	if len(latest) > 0 {
+		lastID = latest[0].ID
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		packets, err := getPackets(ctx, packetFilter{AfterID: lastID, Protocol: protocol, Limit: maxPacketLimit})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("Database error: %v", err)
+			continue
+		}
+
+		slices.Reverse(packets)
+		for _, p := range packets {
+			fmt.Fprintln(out, formatTailLine(p))
+			lastID = max(lastID, p.ID)
+		}
+	}
+}
+
+func formatTailLine(p PacketInfo) string {
+	line := fmt.Sprintf("%s  #%-8d %-5s %15s -> %-15s len=%d ttl=%d",
+		p.CheckedAt.Format("2006-01-02 15:04:05"), p.ID, p.Protocol,
+		p.SourceIP, p.DestinationIP, p.TotalLength, p.TTL)
+	if p.Flags != "" {
+		line += " flags=" + p.Flags
+	}
+	if p.Malicious > 0 || p.Suspicious > 0 {
+		line += fmt.Sprintf("  malicious=%d suspicious=%d", p.Malicious, p.Suspicious)
+	}
+	return line
+}
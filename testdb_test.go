@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// createPacketInfo is the packet_info layout the server expects;
+// migrate adds the optional columns.
+const createPacketInfo = `
+	CREATE TABLE packet_info (
+		id              serial PRIMARY KEY,
+		version         text        NOT NULL,
+		total_length    bigint      NOT NULL,
+		flags           text,
+		ttl             integer     NOT NULL,
+		protocol        text        NOT NULL,
+		header_checksum integer     NOT NULL,
+		source_ip       text        NOT NULL,
+		destination_ip  text        NOT NULL,
+		malicious       integer     NOT NULL DEFAULT 0,
+		suspicious      integer     NOT NULL DEFAULT 0,
+		harmless        integer     NOT NULL DEFAULT 0,
+		undetected      integer     NOT NULL DEFAULT 0,
+		scan_date       timestamptz,
+		checked_at      timestamptz NOT NULL DEFAULT now()
+	)
+`
+
+// openTestDB points db at an empty packet_info in a scratch schema of the
+// Postgres database named by TEST_DB_URL, skipping the test without one.
+// The schema is dropped when the test ends.
+func openTestDB(tb testing.TB) {
+	tb.Helper()
+	base := os.Getenv("TEST_DB_URL")
+	if base == "" {
+		tb.Skip("TEST_DB_URL not set")
+	}
+
+	schema := fmt.Sprintf("netmon_test_%d", time.Now().UnixNano())
+	admin, err := sql.Open("postgres", base)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		tb.Fatal(err)
+	}
+
+	// lib/pq sends unknown connection parameters as session settings.
+	dsn := base + " search_path=" + schema
+	if u, err := url.Parse(base); err == nil && strings.HasPrefix(u.Scheme, "postgres") {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		dsn = u.String()
+	}
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	prev := dbConn.Swap(conn)
+	// Cached statements belong to the pool they were prepared on.
+	flushStmtCache()
+	tb.Cleanup(func() {
+		flushStmtCache()
+		conn.Close()
+		dbConn.Store(prev)
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	if _, err := db().Exec(createPacketInfo); err != nil {
+		tb.Fatal(err)
+	}
+	if err := migrate(context.Background()); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// insertTestPackets adds n TCP packets and returns their ids in order.
+func insertTestPackets(tb testing.TB, n int) []int {
+	tb.Helper()
+	return insertTestPacketsWith(tb, db(), n)
+}
+
+// insertTestPacketsWith is insertTestPackets through q, e.g. a transaction.
+func insertTestPacketsWith(tb testing.TB, q querier, n int) []int {
+	tb.Helper()
+	rows, err := q.QueryContext(context.Background(), `
+		INSERT INTO packet_info (version, total_length, ttl, protocol, header_checksum, source_ip, destination_ip)
+		SELECT 'IPv4', 60, 64, 'TCP', 0, '10.0.0.1', '10.0.0.2' FROM generate_series(1, $1)
+		RETURNING id
+	`, n)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			tb.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		tb.Fatal(err)
+	}
+	return ids
+}
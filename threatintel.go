@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// threatProviderFactories builds each known provider from its API key
+// variable. A provider without a key is unavailable.
+var threatProviderFactories = map[string]struct {
+	keyEnv string
+	build  func(apiKey string) ThreatProvider
+}{
+	"virustotal": {"VIRUSTOTAL_API_KEY", func(k string) ThreatProvider { return newVirusTotalClient(k) }},
+	"abuseipdb":  {"ABUSEIPDB_API_KEY", func(k string) ThreatProvider { return newAbuseIPDBClient(k) }},
+}
+
+// configureThreatProviders builds the providers named in list
+// (THREAT_PROVIDERS, comma-separated). An empty list means every provider
+// whose API key is set. It returns nil when none is configured.
+func configureThreatProviders(list string) (ThreatProvider, error) {
+	var names []string
+	if strings.TrimSpace(list) == "" {
+		for _, name := range []string{"virustotal", "abuseipdb"} {
+			if os.Getenv(threatProviderFactories[name].keyEnv) != "" {
+				names = append(names, name)
+			}
+		}
+	} else {
+		for _, name := range strings.Split(list, ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var providers []ThreatProvider
+	for _, name := range names {
+		f, ok := threatProviderFactories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown threat provider %q", name)
+		}
+		key := os.Getenv(f.keyEnv)
+		if key == "" {
+			return nil, fmt.Errorf("threat provider %s needs %s", name, f.keyEnv)
+		}
+		providers = append(providers, f.build(key))
+	}
+
+	switch len(providers) {
+	case 0:
+		return nil, nil
+	case 1:
+		return providers[0], nil
+	}
+	return multiProvider(providers), nil
+}
+
+// multiProvider asks every provider and adds up their verdicts, so each
+// provider counts like one more set of engines. Providers that fail are
+// logged and left out; the lookup fails only if all of them do.
+type multiProvider []ThreatProvider
+
+func (m multiProvider) Name() string {
+	names := make([]string, len(m))
+	for i, p := range m {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, "+")
+}
+
+func (m multiProvider) Lookup(ctx context.Context, ip string) (Reputation, error) {
+	type result struct {
+		name string
+		rep  Reputation
+		err  error
+	}
+	results := make(chan result, len(m))
+	for _, p := range m {
+		go func() {
+			rep, err := p.Lookup(ctx, ip)
+			results <- result{p.Name(), rep, err}
+		}()
+	}
+
+	var total Reputation
+	var errs []error
+	for range m {
+		r := <-results
+		if r.err != nil {
+			log.Printf("Threat provider %s lookup for %s failed: %v", r.name, ip, r.err)
+			errs = append(errs, fmt.Errorf("%s: %w", r.name, r.err))
+			continue
+		}
+		total.Malicious += r.rep.Malicious
+		total.Suspicious += r.rep.Suspicious
+		total.Harmless += r.rep.Harmless
+		total.Undetected += r.rep.Undetected
+		if r.rep.ScanDate.After(total.ScanDate) {
+			total.ScanDate = r.rep.ScanDate
+		}
+	}
+	if len(errs) == len(m) {
+		return Reputation{}, errors.Join(errs...)
+	}
+	return total, nil
+}
+
+// AbuseIPDB confidence scores (0-100) at or above these count as a
+// malicious or suspicious verdict.
+const (
+	abuseMaliciousScore  = 75
+	abuseSuspiciousScore = 25
+)
+
+type abuseIPDBClient struct {
+	apiKey string
+	client *http.Client
+}
+
+func newAbuseIPDBClient(apiKey string) *abuseIPDBClient {
+	return &abuseIPDBClient{apiKey: apiKey, client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (a *abuseIPDBClient) Name() string { return "abuseipdb" }
+
+// Lookup maps AbuseIPDB's single confidence score onto one vote: malicious,
+// suspicious, harmless (reported but low score) or undetected (no reports).
+func (a *abuseIPDBClient) Lookup(ctx context.Context, ip string) (Reputation, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"https://api.abuseipdb.com/api/v2/check?maxAgeInDays=90&ipAddress="+url.QueryEscape(ip), nil)
+	if err != nil {
+		return Reputation{}, err
+	}
+	req.Header.Set("Key", a.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return Reputation{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Reputation{}, fmt.Errorf("abuseipdb: unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		Data struct {
+			AbuseConfidenceScore int `json:"abuseConfidenceScore"`
+			TotalReports         int `json:"totalReports"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Reputation{}, fmt.Errorf("abuseipdb: %w", err)
+	}
+
+	d := body.Data
+	rep := Reputation{ScanDate: time.Now()}
+	switch {
+	case d.AbuseConfidenceScore >= abuseMaliciousScore:
+		rep.Malicious = 1
+	case d.AbuseConfidenceScore >= abuseSuspiciousScore:
+		rep.Suspicious = 1
+	case d.TotalReports > 0:
+		rep.Harmless = 1
+	default:
+		rep.Undetected = 1
+	}
+	return rep, nil
+}
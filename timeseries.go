@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// epochTruncate rounds t down to a multiple of d since the Unix epoch, the
+// same alignment as timeBucketSQL. (time.Truncate aligns to the zero Time.)
+func epochTruncate(t time.Time, d time.Duration) time.Time {
+	ns := t.UnixNano()
+	return time.Unix(0, ns-ns%int64(d))
+}
+
+type protocolTimeseries struct {
+	Buckets   []time.Time
+	Protocols []string
+	Counts    map[string][]int // per protocol, aligned with Buckets
+}
+
+// getProtocolTimeseries counts packets per protocol in consecutive
+// interval-sized buckets from the one containing since up to the current
+// one, keeping at most limit of the most recent buckets. Buckets without
+// traffic are included with zero counts.
+func getProtocolTimeseries(ctx context.Context, since time.Time, interval time.Duration, limit int) (protocolTimeseries, error) {
+	first := epochTruncate(since, interval)
+	last := epochTruncate(time.Now(), interval)
+	if n := int(last.Sub(first)/interval) + 1; n > limit {
+		first = last.Add(-time.Duration(limit-1) * interval)
+	}
+
+	ts := protocolTimeseries{Counts: map[string][]int{}}
+	for b := first; !b.After(last); b = b.Add(interval) {
+		ts.Buckets = append(ts.Buckets, b)
+	}
+
+	query := `
+		SELECT ` + timeBucketSQL + ` AS bucket, protocol, COUNT(*)
+		FROM packet_info
+		WHERE checked_at >= $2
+		GROUP BY bucket, protocol
+	`
+
+	rows, err := db().QueryContext(ctx, tagQuery(query), interval.Seconds(), first)
+	if err != nil {
+		return ts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bucket time.Time
+		var protocol string
+		var count int
+		if err := rows.Scan(&bucket, &protocol, &count); err != nil {
+			return ts, err
+		}
+		i := int(epochTruncate(bucket, interval).Sub(first) / interval)
+		if i < 0 || i >= len(ts.Buckets) {
+			continue
+		}
+		counts, ok := ts.Counts[protocol]
+		if !ok {
+			counts = make([]int, len(ts.Buckets))
+			ts.Counts[protocol] = counts
+			ts.Protocols = append(ts.Protocols, protocol)
+		}
+		counts[i] = count
+	}
+	slices.Sort(ts.Protocols)
+
+	return ts, rows.Err()
+}
+
+// handleTimeseriesCSV exports bucketed protocol counts for reporting tools:
+// one row per bucket with a column per protocol and a total.
+func handleTimeseriesCSV(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	interval, err := durationParam(r, "interval", time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	window, err := windowParam(r, "window", 24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := statsLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ts, err := getProtocolTimeseries(ctx, time.Now().Add(-window), interval, limit)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="timeseries.csv"`)
+
+	cw := csv.NewWriter(w)
+	cw.Write(append(append([]string{"bucket"}, ts.Protocols...), "total"))
+	for i, b := range ts.Buckets {
+		row := []string{b.UTC().Format(time.RFC3339)}
+		total := 0
+		for _, p := range ts.Protocols {
+			n := ts.Counts[p][i]
+			total += n
+			row = append(row, strconv.Itoa(n))
+		}
+		cw.Write(append(row, strconv.Itoa(total)))
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Printf("CSV write error: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseCipherSuites resolves a comma-separated list of Go cipher suite
+// names (e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256). Suites Go considers
+// insecure are rejected rather than silently enabled.
+func parseCipherSuites(s string) ([]uint16, error) {
+	known := map[string]uint16{}
+	for _, c := range tls.CipherSuites() {
+		known[c.Name] = c.ID
+	}
+	insecure := map[string]bool{}
+	for _, c := range tls.InsecureCipherSuites() {
+		insecure[c.Name] = true
+	}
+
+	var ids []uint16
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := known[name]
+		if !ok {
+			if insecure[name] {
+				return nil, fmt.Errorf("cipher suite %s is insecure", name)
+			}
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// serverTLSConfig builds the listener's TLS settings from TLS_MIN_VERSION
+// (default 1.2) and TLS_CIPHER_SUITES (default Go's secure set). The cipher
+// list only affects TLS 1.2 and below; Go does not make TLS 1.3 suites
+// configurable.
+func serverTLSConfig(minVersion, ciphers string) (*tls.Config, error) {
+	if minVersion == "" {
+		minVersion = "1.2"
+	}
+	v, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("invalid TLS_MIN_VERSION %q (want 1.0, 1.1, 1.2 or 1.3)", minVersion)
+	}
+	cfg := &tls.Config{MinVersion: v}
+	if ciphers != "" {
+		ids, err := parseCipherSuites(ciphers)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS_CIPHER_SUITES: %w", err)
+		}
+		cfg.CipherSuites = ids
+	}
+	return cfg, nil
+}
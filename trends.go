@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+type ratioPoint struct {
+	Bucket    time.Time `json:"bucket"`
+	Total     int       `json:"total"`
+	Malicious int       `json:"malicious"`
+	RatioPct  float64   `json:"ratio_pct"`
+}
+
+// timeBucketSQL truncates checked_at to a multiple of $1 seconds since the
+// Unix epoch.
+const timeBucketSQL = `to_timestamp(FLOOR(EXTRACT(EPOCH FROM checked_at) / $1) * $1)`
+
+// getMaliciousRatio returns, per interval-sized bucket since the given time,
+// the percentage of packets with at least one malicious verdict.
+func getMaliciousRatio(ctx context.Context, since time.Time, interval time.Duration, limit int) ([]ratioPoint, error) {
+	query := `
+		SELECT ` + timeBucketSQL + ` AS bucket,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE malicious > 0),
+		       100.0 * COUNT(*) FILTER (WHERE malicious > 0) / COUNT(*)
+		FROM packet_info
+		WHERE checked_at >= $2
+		GROUP BY bucket
+		ORDER BY bucket DESC
+		LIMIT $3
+	`
+
+	rows, err := db().QueryContext(ctx, tagQuery(query), interval.Seconds(), since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []ratioPoint{}
+	for rows.Next() {
+		var p ratioPoint
+		if err := rows.Scan(&p.Bucket, &p.Total, &p.Malicious, &p.RatioPct); err != nil {
+			return nil, err
+		}
+		results = append(results, p)
+	}
+
+	// Fetched newest first so the limit keeps the most recent buckets.
+	slices.Reverse(results)
+	return results, rows.Err()
+}
+
+func handleMaliciousRatioAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := queryContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	interval, err := durationParam(r, "interval", time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	window, err := windowParam(r, "window", 24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := statsLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := getMaliciousRatio(ctx, time.Now().Add(-window), interval, limit)
+	if err != nil {
+		http.Error(w, "Error fetching data", http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	writeJSON(w, r, results)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+)
+
+type filterValidation struct {
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+	Where string `json:"where,omitempty"`
+	Args  int    `json:"args"`
+}
+
+// handleValidateFilter checks /api/packets filter parameters (in the query
+// string or a form-encoded body) without touching the database. A valid
+// filter comes back with the WHERE clause it would run, placeholders only.
+func handleValidateFilter(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// parsePacketFilter reads the query string, so hand it the merged
+	// query and body parameters.
+	params := r.Clone(r.Context())
+	u := *r.URL
+	u.RawQuery = r.Form.Encode()
+	params.URL = &u
+
+	filter, err := parsePacketFilter(params)
+	if err != nil {
+		writeJSON(w, r, filterValidation{Error: err.Error()})
+		return
+	}
+	where, args := filter.listingWhere()
+	writeJSON(w, r, filterValidation{Valid: true, Where: where, Args: len(args)})
+}
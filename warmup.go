@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// warmup runs the dashboard's common queries once so the first real request
+// hits warm Postgres caches. A failure here usually means the packet_info
+// schema doesn't match what the dashboard expects.
+func warmup() error {
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeoutMax)
+	defer cancel()
+
+	if _, err := getPackets(ctx, packetFilter{Limit: defaultPacketLimit}); err != nil {
+		return err
+	}
+	if _, err := getWindowStats(ctx, start.Add(-2*time.Hour), start); err != nil {
+		return err
+	}
+
+	log.Printf("Warmup completed in %s", time.Since(start).Round(time.Millisecond))
+	return nil
+}